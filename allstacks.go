@@ -0,0 +1,237 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frame - a func call frame of a goroutine stack.
+type Frame struct {
+	Func string // full func name such as github.com/phcurtis/fn.Lvl
+	File string
+	Line int
+}
+
+// Info - returns frame as one string in the form of LvlInfoStr
+// with func name and filename adjusted according to flags value.
+func (f Frame) Info(flags int) string {
+	return fmt.Sprintf("%s:%d:%s", infoFile(f.File, flags), f.Line, infoName(f.Func, flags))
+}
+
+// String - returns frame as one string adjusted to IflagsCmn flags value.
+func (f Frame) String() string {
+	return f.Info(IflagsCmn)
+}
+
+// GStack - a goroutine and its call stack, see AllStacks.
+type GStack struct {
+	ID        int64
+	State     string        // such as running, runnable, chan receive, select, IO wait
+	Wait      time.Duration // time blocked as reported by runtime (1 minute resolution)
+	Locked    bool          // locked to thread
+	Frames    []Frame       // innermost (leaf) first
+	Elided    bool          // runtime elided some frames of a deep stack
+	CreatedBy Frame         // go statement that created goroutine, zero if none
+	ParentID  int64         // goroutine that created it, 0 if unknown
+}
+
+// AllStacksBufDef - initial size of buffer for runtime.Stack used in AllStacks.
+const AllStacksBufDef = 64 << 10
+
+// AllStacks - returns the call stacks of all goroutines parsed from
+// runtime.Stack(buf, true), the invoking goroutine first.
+func AllStacks() []GStack {
+	buf := make([]byte, AllStacksBufDef)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return ParseStacks(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+var gHdrRe = regexp.MustCompile(`^goroutine (\d+) .*?\[(.*)\]:$`)
+
+// ParseStacks - returns the goroutine stacks parsed from text in the format
+// of runtime.Stack(buf, true) and of the goroutine dump of a panic.
+// Lines not part of a goroutine stack are ignored.
+func ParseStacks(b []byte) []GStack {
+	var res []GStack
+	var g *GStack
+	var fr *Frame
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			g, fr = nil, nil
+		case g == nil:
+			if m := gHdrRe.FindStringSubmatch(line); m != nil {
+				res = append(res, GStack{})
+				g = &res[len(res)-1]
+				g.ID, _ = strconv.ParseInt(m[1], 10, 64)
+				g.parseState(m[2])
+			}
+		case strings.HasPrefix(line, "\t"):
+			if fr != nil {
+				fr.File, fr.Line = parseFileLine(line)
+				fr = nil
+			}
+		case line == "...additional frames elided...":
+			g.Elided = true
+		case strings.HasPrefix(line, "created by "):
+			line = line[len("created by "):]
+			if i := strings.Index(line, " in goroutine "); i >= 0 {
+				g.ParentID, _ = strconv.ParseInt(line[i+len(" in goroutine "):], 10, 64)
+				line = line[:i]
+			}
+			g.CreatedBy = Frame{Func: line}
+			fr = &g.CreatedBy
+		default:
+			g.Frames = append(g.Frames, Frame{Func: trimArgs(line)})
+			fr = &g.Frames[len(g.Frames)-1]
+		}
+	}
+	return res
+}
+
+// parseState - sets state fields from the bracketed part of a goroutine
+// header such as "chan receive, 5 minutes, locked to thread".
+func (g *GStack) parseState(s string) {
+	parts := strings.Split(s, ", ")
+	g.State = parts[0]
+	for _, p := range parts[1:] {
+		switch {
+		case p == "locked to thread":
+			g.Locked = true
+		case strings.HasSuffix(p, " minutes"), strings.HasSuffix(p, " minute"):
+			if n, err := strconv.Atoi(p[:strings.Index(p, " ")]); err == nil {
+				g.Wait = time.Duration(n) * time.Minute
+			}
+		}
+	}
+}
+
+// trimArgs - returns func name of a stack func line without its trailing
+// argument list such as "(0xc000010000, 0x1)" or "(...)".
+func trimArgs(s string) string {
+	if !strings.HasSuffix(s, ")") {
+		return s
+	}
+	depth := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		switch s[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
+
+// parseFileLine - returns filename and line num of a stack file line
+// such as "\t/go/src/pkg/file.go:42 +0x1d".
+func parseFileLine(s string) (file string, line int) {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, " +0x"); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		if n, err := strconv.Atoi(s[i+1:]); err == nil {
+			return s[:i], n
+		}
+	}
+	return s, 0
+}
+
+// StackGroup - goroutines with identical state and call stacks, see GroupStacks.
+type StackGroup struct {
+	State  string
+	Wait   time.Duration // max Wait of the goroutines
+	IDs    []int64
+	Frames []Frame
+}
+
+// GroupStacks - returns stacks grouped by identical state and frames,
+// ordered by decreasing number of goroutines then by first goroutine id.
+func GroupStacks(stacks []GStack) []StackGroup {
+	var res []StackGroup
+	idx := make(map[string]int)
+	var key strings.Builder
+	for _, g := range stacks {
+		key.Reset()
+		key.WriteString(g.State)
+		for _, f := range g.Frames {
+			fmt.Fprintf(&key, "\x00%s\x00%s:%d", f.Func, f.File, f.Line)
+		}
+		i, ok := idx[key.String()]
+		if !ok {
+			i = len(res)
+			idx[key.String()] = i
+			res = append(res, StackGroup{State: g.State, Frames: g.Frames})
+		}
+		res[i].IDs = append(res[i].IDs, g.ID)
+		if g.Wait > res[i].Wait {
+			res[i].Wait = g.Wait
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if len(res[i].IDs) != len(res[j].IDs) {
+			return len(res[i].IDs) > len(res[j].IDs)
+		}
+		return res[i].IDs[0] < res[j].IDs[0]
+	})
+	return res
+}
+
+// FprintStacks - writes to w the stacks of all goroutines grouped by
+// identical stacks with frames adjusted according to LvlInfo flags value,
+// a compact alternative to the raw goroutine dump of a panic.
+//
+//	Example output with flags=IflagsShort:
+//	2 goroutines [chan receive, 3m0s] ids:7,8
+//	    worker.go:12:main.worker()
+//	    main.go:30:main.main.func1()
+func FprintStacks(w io.Writer, flags int) {
+	for _, sg := range GroupStacks(AllStacks()) {
+		sg.Fprint(w, flags)
+	}
+}
+
+// Fprint - writes group to w with frames adjusted according to LvlInfo flags value.
+func (sg StackGroup) Fprint(w io.Writer, flags int) {
+	gor := "goroutines"
+	if len(sg.IDs) == 1 {
+		gor = "goroutine"
+	}
+	state := sg.State
+	if sg.Wait > 0 {
+		state += ", " + sg.Wait.String()
+	}
+	ids := make([]string, len(sg.IDs))
+	for i, id := range sg.IDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	fmt.Fprintf(w, "%d %s [%s] ids:%s\n", len(sg.IDs), gor, state, strings.Join(ids, ","))
+	for _, f := range sg.Frames {
+		fmt.Fprintf(w, "    %s\n", f.Info(flags))
+	}
+}
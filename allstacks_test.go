@@ -0,0 +1,115 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phcurtis/fn"
+)
+
+const rawStacks = `goroutine 1 [running]:
+main.main()
+	/home/u/go/src/ex/main.go:12 +0xae
+
+goroutine 7 [chan receive, 5 minutes]:
+ex/work.(*Pool).worker(0xc000010000, {0x4b2e60, 0x1})
+	/home/u/go/src/ex/work/pool.go:40 +0x19
+created by ex/work.New in goroutine 1
+	/home/u/go/src/ex/work/pool.go:22 +0x76
+
+goroutine 8 [chan receive, 7 minutes, locked to thread]:
+ex/work.(*Pool).worker(...)
+	/home/u/go/src/ex/work/pool.go:40
+created by ex/work.New
+	/home/u/go/src/ex/work/pool.go:22 +0x76
+
+goroutine 9 [select]:
+ex/deep.recurse(0x3)
+	/home/u/go/src/ex/deep/deep.go:9 +0x25
+...additional frames elided...
+`
+
+func TestParseStacks(t *testing.T) {
+	worker := fn.Frame{Func: "ex/work.(*Pool).worker", File: "/home/u/go/src/ex/work/pool.go", Line: 40}
+	created := fn.Frame{Func: "ex/work.New", File: "/home/u/go/src/ex/work/pool.go", Line: 22}
+	want := []fn.GStack{
+		{ID: 1, State: "running",
+			Frames: []fn.Frame{{Func: "main.main", File: "/home/u/go/src/ex/main.go", Line: 12}}},
+		{ID: 7, State: "chan receive", Wait: 5 * time.Minute,
+			Frames: []fn.Frame{worker}, CreatedBy: created, ParentID: 1},
+		{ID: 8, State: "chan receive", Wait: 7 * time.Minute, Locked: true,
+			Frames: []fn.Frame{worker}, CreatedBy: created},
+		{ID: 9, State: "select", Elided: true,
+			Frames: []fn.Frame{{Func: "ex/deep.recurse", File: "/home/u/go/src/ex/deep/deep.go", Line: 9}}},
+	}
+	got := fn.ParseStacks([]byte("panic: boom\n\n" + rawStacks))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("\ngot: %+v\nwant:%+v", got, want)
+	}
+
+	groups := fn.GroupStacks(got)
+	if len(groups) != 3 {
+		t.Fatalf("groups got:%d want:3", len(groups))
+	}
+	if g := groups[0]; !reflect.DeepEqual(g.IDs, []int64{7, 8}) || g.Wait != 7*time.Minute {
+		t.Errorf("groups[0] unexpected:%+v", g)
+	}
+	var buf bytes.Buffer
+	groups[0].Fprint(&buf, fn.IflagsShort)
+	wantOut := "2 goroutines [chan receive, 7m0s] ids:7,8\n    pool.go:40:work.(*Pool).worker()\n"
+	if buf.String() != wantOut {
+		t.Errorf("\ngot: %q\nwant:%q", buf.String(), wantOut)
+	}
+	if s := groups[1].Frames[0].Info(fn.Ifilelong | fn.Ifuncnoparens); s != "/home/u/go/src/ex/main.go:12:main.main" {
+		t.Errorf("Info got:%q", s)
+	}
+}
+
+func stackWorker(c chan struct{}) { <-c }
+
+func TestAllStacks(t *testing.T) {
+	const n = 3
+	c := make(chan struct{})
+	defer close(c)
+	for i := 0; i < n; i++ {
+		go stackWorker(c)
+	}
+	name := "github.com/phcurtis/" + pkgName + ".stackWorker"
+	var grp *fn.StackGroup
+	for try := 0; try < 100 && grp == nil; try++ {
+		time.Sleep(time.Millisecond)
+		stacks := fn.AllStacks()
+		if stacks[0].State != "running" ||
+			stacks[0].Frames[0].Func != "github.com/phcurtis/fn.AllStacks" {
+			t.Fatalf("first stack should be invoking goroutine got:%+v", stacks[0])
+		}
+		for _, sg := range fn.GroupStacks(stacks) {
+			if len(sg.IDs) == n && sg.Frames[0].Func == name {
+				grp = &sg
+				break
+			}
+		}
+	}
+	if grp == nil {
+		t.Fatalf("no group of %d goroutines in %s", n, name)
+	}
+	if grp.State != "chan receive" {
+		t.Errorf("state got:%q want:%q", grp.State, "chan receive")
+	}
+
+	var buf bytes.Buffer
+	fn.FprintStacks(&buf, fn.IflagsShort)
+	want := "3 goroutines [chan receive] ids:"
+	if !strings.Contains(buf.String(), want) ||
+		!strings.Contains(buf.String(), "allstacks_test.go:") ||
+		!strings.Contains(buf.String(), ":"+pkgName+".stackWorker()\n") {
+		t.Errorf("FprintStacks missing %q or stackWorker frame:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Async overflow policies used by NewAsyncWriter when its queue is full.
+const (
+	AsyncBlock      = iota // block the writer until the queue has room
+	AsyncDropNewest        // drop the line being written
+	AsyncDropOldest        // drop the oldest queued line
+)
+
+// AsyncQueueDef - default AsyncWriter queue size (in lines).
+const AsyncQueueDef = 1024
+
+// ErrAsyncClosed - returned when writing to a closed AsyncWriter.
+var ErrAsyncClosed = errors.New("fn: AsyncWriter closed")
+
+// AsyncWriter - an io.Writer that queues each Write into a bounded ring
+// and writes them to the underlying writer from a background goroutine,
+// so a slow output does not stall traced goroutines waiting on the package log mutex.
+// When lines are dropped due to the overflow policy, a line reporting
+// the number dropped is written ahead of the next queued line.
+//
+//	Typical usage: aw := fn.NewAsyncWriter(os.Stderr, 0, fn.AsyncDropOldest)
+//	               fn.LogSetOutput(aw); defer aw.Close()
+type AsyncWriter struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	wr      io.Writer
+	ring    [][]byte
+	head    int
+	n       int
+	policy  int
+	dropped uint64 // dropped since last drop counter line
+	total   uint64 // dropped since creation
+	busy    bool   // background writer is writing
+	closed  bool
+	err     error // first error from underlying writer
+	done    chan struct{}
+}
+
+// NewAsyncWriter - returns an AsyncWriter writing to wr with a queue
+// holding size lines (AsyncQueueDef if size < 1) and the given overflow
+// policy (AsyncBlock, AsyncDropNewest or AsyncDropOldest).
+func NewAsyncWriter(wr io.Writer, size int, policy int) *AsyncWriter {
+	if size < 1 {
+		size = AsyncQueueDef
+	}
+	a := &AsyncWriter{
+		wr:     wr,
+		ring:   make([][]byte, size),
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	a.cond = sync.NewCond(&a.mu)
+	go a.run()
+	return a
+}
+
+// Write - queues a copy of p, applying the overflow policy when full.
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return 0, ErrAsyncClosed
+	}
+	for a.n == len(a.ring) {
+		switch a.policy {
+		case AsyncDropNewest:
+			a.dropped++
+			a.total++
+			return len(p), nil
+		case AsyncDropOldest:
+			a.ring[a.head] = nil
+			a.head = (a.head + 1) % len(a.ring)
+			a.n--
+			a.dropped++
+			a.total++
+		default:
+			a.cond.Wait()
+			if a.closed {
+				return 0, ErrAsyncClosed
+			}
+		}
+	}
+	a.ring[(a.head+a.n)%len(a.ring)] = append([]byte(nil), p...)
+	a.n++
+	a.cond.Broadcast()
+	return len(p), nil
+}
+
+// background writer
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+	a.mu.Lock()
+	for {
+		for a.n == 0 && a.dropped == 0 && !a.closed {
+			a.cond.Wait()
+		}
+		if a.n == 0 && a.dropped == 0 {
+			a.mu.Unlock()
+			return
+		}
+		dropped := a.dropped
+		a.dropped = 0
+		var line []byte
+		if a.n > 0 {
+			line = a.ring[a.head]
+			a.ring[a.head] = nil
+			a.head = (a.head + 1) % len(a.ring)
+			a.n--
+		}
+		a.busy = true
+		a.cond.Broadcast()
+		a.mu.Unlock()
+
+		var err error
+		if dropped > 0 {
+			_, err = fmt.Fprintf(a.wr, "AsyncWriter: dropped %d lines\n", dropped)
+		}
+		if line != nil && err == nil {
+			_, err = a.wr.Write(line)
+		}
+
+		a.mu.Lock()
+		if err != nil && a.err == nil {
+			a.err = err
+		}
+		a.busy = false
+		a.cond.Broadcast()
+	}
+}
+
+// Flush - waits until all queued lines have been written and returns
+// the first error the underlying writer returned if any.
+func (a *AsyncWriter) Flush() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for a.n > 0 || a.dropped > 0 || a.busy {
+		a.cond.Wait()
+	}
+	return a.err
+}
+
+// Close - flushes queued lines and stops the background writer;
+// subsequent writes return ErrAsyncClosed. If the underlying writer
+// is an io.Closer it is NOT closed.
+func (a *AsyncWriter) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	a.cond.Broadcast()
+	a.mu.Unlock()
+	<-a.done
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// Dropped - returns the total number of lines dropped due to overflow.
+func (a *AsyncWriter) Dropped() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.total
+}
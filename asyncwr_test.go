@@ -0,0 +1,103 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/phcurtis/fn"
+)
+
+// gatedWriter - signals entered then blocks each Write until gate is closed.
+type gatedWriter struct {
+	entered chan struct{}
+	gate    chan struct{}
+	mu      sync.Mutex
+	buf     bytes.Buffer
+}
+
+func (g *gatedWriter) Write(p []byte) (int, error) {
+	select {
+	case g.entered <- struct{}{}:
+	default:
+	}
+	<-g.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.buf.Write(p)
+}
+
+func TestAsyncWriterOrder(t *testing.T) {
+	var buf bytes.Buffer
+	aw := fn.NewAsyncWriter(&buf, 4, fn.AsyncBlock)
+	var want string
+	for i := 0; i < 100; i++ {
+		line := fmt.Sprintf("line%d\n", i)
+		want += line
+		fmt.Fprint(aw, line)
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatalf("Close err:%v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("\n got:%s \nwant:%s", got, want)
+	}
+	if _, err := aw.Write([]byte("x\n")); err != fn.ErrAsyncClosed {
+		t.Errorf("write after close err got:%v want:%v", err, fn.ErrAsyncClosed)
+	}
+}
+
+func TestAsyncWriterDrop(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy int
+		want   string
+	}{
+		{"dropnewest", fn.AsyncDropNewest, "l0\nAsyncWriter: dropped 4 lines\nl1\nl2\n"},
+		{"dropoldest", fn.AsyncDropOldest, "l0\nAsyncWriter: dropped 4 lines\nl5\nl6\n"},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			gw := &gatedWriter{entered: make(chan struct{}, 1), gate: make(chan struct{})}
+			aw := fn.NewAsyncWriter(gw, 2, v.policy)
+			fmt.Fprint(aw, "l0\n")
+			<-gw.entered // background writer now blocked writing l0
+			for i := 1; i <= 6; i++ {
+				fmt.Fprintf(aw, "l%d\n", i)
+			}
+			if got := aw.Dropped(); got != 4 {
+				t.Errorf("Dropped got:%d want:4", got)
+			}
+			close(gw.gate)
+			if err := aw.Close(); err != nil {
+				t.Fatalf("Close err:%v", err)
+			}
+			if got := gw.buf.String(); got != v.want {
+				t.Errorf("\n got:%q \nwant:%q", got, v.want)
+			}
+		})
+	}
+}
+
+func TestAsyncWriterLogOutput(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	var buf bytes.Buffer
+	aw := fn.NewAsyncWriter(&buf, 0, fn.AsyncBlock)
+	fn.LogSetOutput(aw)
+	fn.LogSetFlags(fn.LflagsOff)
+	fn.LogTrace()()
+	if err := aw.Flush(); err != nil {
+		t.Fatalf("Flush err:%v", err)
+	}
+	got := buf.String()
+	if strings.Count(got, "\n") != 2 || !strings.Contains(got, fn.LendTraceLab) {
+		t.Errorf("unexpected trace output:%q", got)
+	}
+	aw.Close()
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Attr - a key/value attribute attached to a trace line.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// AttrBadKey - key used for an attribute whose key is not a string
+// or whose value is missing (odd number of args).
+const AttrBadKey = "!BADKEY"
+
+// Attrs - converts alternating key, value args into a slice of Attr.
+// An Attr passed directly is used as is, a non string key is stored under
+// AttrBadKey with the offending key as its value, and a dangling key
+// without a value is stored under AttrBadKey. A value of type
+// func() interface{} is called and its result stored, so a pairing end
+// func of a deferred call can log values computed later in the func.
+func Attrs(kv ...interface{}) []Attr {
+	if len(kv) == 0 {
+		return nil
+	}
+	attrs := make([]Attr, 0, (len(kv)+1)/2)
+	for len(kv) > 0 {
+		switch k := kv[0].(type) {
+		case Attr:
+			attrs = append(attrs, Attr{k.Key, attrVal(k.Value)})
+			kv = kv[1:]
+		case string:
+			if len(kv) == 1 {
+				attrs = append(attrs, Attr{AttrBadKey, k})
+				kv = kv[1:]
+				break
+			}
+			attrs = append(attrs, Attr{k, attrVal(kv[1])})
+			kv = kv[2:]
+		default:
+			attrs = append(attrs, Attr{AttrBadKey, k})
+			kv = kv[1:]
+		}
+	}
+	return attrs
+}
+
+// attrVal - returns v or for a func() interface{} its result.
+func attrVal(v interface{}) interface{} {
+	if f, ok := v.(func() interface{}); ok {
+		return f()
+	}
+	return v
+}
+
+// String - returns the attribute in key=value form, quoting the value
+// if it is empty or contains spaces, quotes or '='.
+func (a Attr) String() string {
+	return a.Key + "=" + attrValStr(a.Value)
+}
+
+func attrValStr(v interface{}) string {
+	var s string
+	switch x := v.(type) {
+	case string:
+		s = x
+	case error:
+		s = x.Error()
+	default:
+		s = fmt.Sprint(x)
+	}
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		s = strconv.Quote(s)
+	}
+	return s
+}
+
+// attrsStr - text form of attrs as space separated key=value pairs,
+// each preceded by a space.
+func attrsStr(attrs []Attr) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, a := range attrs {
+		b.WriteByte(' ')
+		b.WriteString(a.String())
+	}
+	return b.String()
+}
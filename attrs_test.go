@@ -0,0 +1,93 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/phcurtis/fn"
+)
+
+func TestAttrs(t *testing.T) {
+	tests := []struct {
+		name string
+		kv   []interface{}
+		want string
+	}{
+		{"none", nil, "[]"},
+		{"pairs", []interface{}{"userID", 42, "ok", true}, "[userID=42 ok=true]"},
+		{"quoted", []interface{}{"msg", "a b", "empty", ""}, `[msg="a b" empty=""]`},
+		{"error", []interface{}{"err", errors.New("boom")}, "[err=boom]"},
+		{"attr", []interface{}{fn.Attr{Key: "k", Value: "v"}, "n", 1}, "[k=v n=1]"},
+		{"badkey", []interface{}{7, "x"}, "[!BADKEY=7 !BADKEY=x]"},
+		{"dangling", []interface{}{"k", 1, "lonely"}, "[k=1 !BADKEY=lonely]"},
+		{"lazy", []interface{}{"n", func() interface{} { return 3 },
+			fn.Attr{Key: "s", Value: func() interface{} { return "x" }}}, "[n=3 s=x]"},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			got := fmt.Sprint(fn.Attrs(v.kv...))
+			if got != v.want {
+				t.Errorf("%s \n got:%s \nwant:%s \n", v.name, got, v.want)
+			}
+		})
+	}
+}
+
+func TestLogTraceAttrs(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	buf := bytes.NewBufferString("")
+	fn.SetPkgCfgDef(false)
+	fn.LogSetOutput(buf)
+	fn.LogSetFlags(fn.LflagsOff)
+	fn.LogSetTraceFlags(fn.TrFlagsOff)
+
+	fullFN := baseName + "TestLogTraceAttrs"
+	fn.LogTraceAttrs("userID", 42, "name", "go pher")("bytes", 10)
+	wantb := "LogFN: " + fn.LbegTraceAttrLab + fullFN + ` userID=42 name="go pher"`
+	wante := "LogFN: " + fn.LendTraceAttrLab + fullFN + " bytes=10"
+	if got := readStdoutCapLine(buf); got != wantb {
+		t.Errorf("\n got:%s \nwant:%s \n", got, wantb)
+	}
+	if got := readStdoutCapLine(buf); got != wante {
+		t.Errorf("\n got:%s \nwant:%s \n", got, wante)
+	}
+
+	fn.LogSetTraceFlags(fn.Trlogignore)
+	fn.LogTraceAttrs("k", "v")()
+	if got := buf.String(); got != "" {
+		t.Errorf("Trlogignore should log nothing got:%s", got)
+	}
+}
+
+// attrsLazy - logs its final n in the end func of a deferred LogTraceAttrs.
+func attrsLazy() {
+	n := 0
+	defer fn.LogTraceAttrs("k", 1)("n", func() interface{} { return n })
+	n = 5
+}
+
+func TestLogTraceAttrsLazy(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	buf := bytes.NewBufferString("")
+	fn.SetPkgCfgDef(false)
+	fn.LogSetOutput(buf)
+	fn.LogSetFlags(fn.LflagsOff)
+	fn.LogSetTraceFlags(fn.TrFlagsOff)
+
+	attrsLazy()
+	fullFN := baseName + "attrsLazy"
+	wantb := "LogFN: " + fn.LbegTraceAttrLab + fullFN + " k=1"
+	wante := "LogFN: " + fn.LendTraceAttrLab + fullFN + " n=5"
+	if got := readStdoutCapLine(buf); got != wantb {
+		t.Errorf("\n got:%s \nwant:%s \n", got, wantb)
+	}
+	if got := readStdoutCapLine(buf); got != wante {
+		t.Errorf("\n got:%s \nwant:%s \n", got, wante)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const fnPath = "github.com/phcurtis/fn"
+
+// options - controls process.
+type options struct {
+	remove bool
+	pkg    *regexp.Regexp // nil matches all
+	fn     *regexp.Regexp // nil matches all
+}
+
+// edit - replace src[pos:end] with text.
+type edit struct {
+	pos, end int
+	text     string
+}
+
+// process - returns src of filename with trace lines inserted or removed
+// according to opts, formatted by go/format if changed.
+func process(filename string, src []byte, opts options) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if ast.IsGenerated(f) || (opts.pkg != nil && !opts.pkg.MatchString(f.Name.Name)) {
+		return src, nil
+	}
+	name, imp := fnImport(f)
+	if name == "." {
+		return nil, fmt.Errorf("%s: dot import of %s not supported, import it by name", filename, fnPath)
+	}
+	if name == "" {
+		name = "fn"
+		if ident := f.Scope.Lookup(name); ident != nil || importsName(f, name) {
+			return nil, fmt.Errorf("%s: identifier %q already in use, cannot import %s", filename, name, fnPath)
+		}
+	}
+	off := func(p token.Pos) int { return fset.Position(p).Offset }
+
+	var edits []edit
+	var kept int // trace lines remaining
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Body == nil || declares(fd, name) {
+			continue
+		}
+		has := len(fd.Body.List) > 0 && isTraceStmt(fd.Body.List[0], name)
+		match := opts.fn == nil || opts.fn.MatchString(funcName(fd))
+		switch {
+		case opts.remove && has && match:
+			stmt := fd.Body.List[0]
+			edits = append(edits, lineEdit(src, off(stmt.Pos()), off(stmt.End())))
+		case !opts.remove && !has && match:
+			edits = append(edits, insertEdit(src, off(fd.Body.Lbrace)+1, name))
+			kept++
+		case has:
+			kept++
+		}
+	}
+	if len(edits) == 0 {
+		return src, nil
+	}
+
+	uses := countUses(f, name)
+	switch {
+	case imp == nil && kept > 0:
+		edits = append(edits, importEdit(f, src, off))
+	case imp != nil && opts.remove && uses == len(edits):
+		edits = append(edits, importRemoveEdit(f, imp, off))
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].pos > edits[j].pos })
+	out := append([]byte(nil), src...)
+	for _, e := range edits {
+		out = append(out[:e.pos], append([]byte(e.text), out[e.end:]...)...)
+	}
+	return format.Source(out)
+}
+
+// fnImport - returns the name package fn is imported as and its spec,
+// a blank import counts as not imported.
+func fnImport(f *ast.File) (string, *ast.ImportSpec) {
+	for _, imp := range f.Imports {
+		if path, _ := strconv.Unquote(imp.Path.Value); path == fnPath {
+			if imp.Name != nil && imp.Name.Name == "_" {
+				continue
+			}
+			if imp.Name != nil {
+				return imp.Name.Name, imp
+			}
+			return "fn", imp
+		}
+	}
+	return "", nil
+}
+
+// importsName - true if f imports a package whose name might be name.
+func importsName(f *ast.File, name string) bool {
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if (imp.Name != nil && imp.Name.Name == name) ||
+			(imp.Name == nil && (path == name || strings.HasSuffix(path, "/"+name))) {
+			return true
+		}
+	}
+	return false
+}
+
+// declares - true if the receiver, params, results or body of fd declare
+// name, such as 'func apply(fn func())', so name may not refer to package fn.
+func declares(fd *ast.FuncDecl, name string) bool {
+	var found bool
+	check := func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok && id.Name == name && id.Obj != nil {
+			found = true
+		}
+		return !found
+	}
+	if fd.Recv != nil {
+		ast.Inspect(fd.Recv, check)
+	}
+	ast.Inspect(fd.Type, check)
+	ast.Inspect(fd.Body, check)
+	return found
+}
+
+// funcName - returns Name or Recv.Name for methods.
+func funcName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	t := fd.Recv.List[0].Type
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+			continue
+		case *ast.IndexExpr:
+			t = x.X
+			continue
+		case *ast.IndexListExpr:
+			t = x.X
+			continue
+		case *ast.Ident:
+			return x.Name + "." + fd.Name.Name
+		}
+		return fd.Name.Name
+	}
+}
+
+// isTraceStmt - true if stmt is 'defer name.LogTrace()()'.
+func isTraceStmt(stmt ast.Stmt, name string) bool {
+	ds, ok := stmt.(*ast.DeferStmt)
+	if !ok || len(ds.Call.Args) != 0 {
+		return false
+	}
+	inner, ok := ds.Call.Fun.(*ast.CallExpr)
+	if !ok || len(inner.Args) != 0 {
+		return false
+	}
+	sel, ok := inner.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "LogTrace" {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == name
+}
+
+// countUses - number of selector expressions name.X in f.
+func countUses(f *ast.File, name string) int {
+	var n int
+	ast.Inspect(f, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == name && x.Obj == nil {
+				n++
+			}
+		}
+		return true
+	})
+	return n
+}
+
+// importEdit - adds the package fn import.
+func importEdit(f *ast.File, src []byte, off func(token.Pos) int) edit {
+	spec := strconv.Quote(fnPath)
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		if gd.Lparen.IsValid() {
+			p := off(gd.Rparen)
+			if p > 0 && src[p-1] == '\n' {
+				return edit{p, p, spec + "\n"}
+			}
+			return edit{p, p, "\n" + spec + "\n"}
+		}
+		p := off(gd.End())
+		return edit{p, p, "\nimport " + spec + "\n"}
+	}
+	p := off(f.Name.End())
+	return edit{p, p, "\n\nimport " + spec + "\n"}
+}
+
+// importRemoveEdit - removes the package fn import spec (or its decl
+// when the only spec).
+func importRemoveEdit(f *ast.File, imp *ast.ImportSpec, off func(token.Pos) int) edit {
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			if spec == imp && len(gd.Specs) == 1 {
+				return edit{off(gd.Pos()), off(gd.End()), ""}
+			}
+		}
+	}
+	return edit{off(imp.Pos()), off(imp.End()), ""}
+}
+
+// insertEdit - inserts the trace line at pos just after a func body '{'.
+func insertEdit(src []byte, pos int, name string) edit {
+	text := "\n\tdefer " + name + ".LogTrace()()"
+	p := pos
+	for p < len(src) && (src[p] == ' ' || src[p] == '\t' || src[p] == '\r') {
+		p++
+	}
+	if p < len(src) && src[p] != '\n' && src[p] != '}' {
+		text += "\n"
+	}
+	return edit{pos, pos, text}
+}
+
+// lineEdit - removes src[pos:end] including its line when nothing else is on it.
+func lineEdit(src []byte, pos, end int) edit {
+	p, e := pos, end
+	for p > 0 && (src[p-1] == ' ' || src[p-1] == '\t') {
+		p--
+	}
+	for e < len(src) && (src[e] == ' ' || src[e] == '\t' || src[e] == '\r') {
+		e++
+	}
+	if (p == 0 || src[p-1] == '\n') && e < len(src) && src[e] == '\n' {
+		return edit{p, e + 1, ""}
+	}
+	return edit{pos, end, ""}
+}
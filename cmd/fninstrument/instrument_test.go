@@ -0,0 +1,69 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestProcessGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		golden string
+		opts   options
+	}{
+		{"insert", "sample.input", "sample.golden", options{}},
+		{"idempotent", "sample.golden", "sample.golden", options{}},
+		{"remove", "sample.golden", "sample.removed", options{remove: true}},
+		{"removeagain", "sample.removed", "sample.removed", options{remove: true}},
+		{"removenone", "sample.input", "sample.input", options{remove: true}},
+		{"funcfilter", "sample.input", "methods.golden", options{fn: regexp.MustCompile(`^T\.`)}},
+		{"pkgfilter", "sample.input", "sample.input", options{pkg: regexp.MustCompile(`^other$`)}},
+		{"removealias", "alias.input", "alias.golden", options{remove: true}},
+		{"noimport", "noimport.input", "noimport.golden", options{}},
+		{"removenoimport", "noimport.golden", "noimport.removed", options{remove: true}},
+		{"shadow", "shadow.input", "shadow.golden", options{}},
+		{"blank", "blank.input", "blank.golden", options{}},
+		{"removeblank", "blank.golden", "blank.input", options{remove: true}},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			got, err := process(v.input, readTestdata(t, v.input), v.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := readTestdata(t, v.golden); string(got) != string(want) {
+				t.Errorf("%s \n got:\n%s \nwant:\n%s", v.name, got, want)
+			}
+		})
+	}
+}
+
+func TestProcessNameConflict(t *testing.T) {
+	src := []byte("package p\n\nimport \"example.com/fn\"\n\nfunc a() { fn.X() }\n")
+	if _, err := process("p.go", src, options{}); err == nil {
+		t.Error("expected error when identifier fn is already imported")
+	}
+}
+
+func TestProcessDotImport(t *testing.T) {
+	src := []byte("package p\n\nimport . \"github.com/phcurtis/fn\"\n\nfunc a() { LogCondMsg(true, \"m\") }\n")
+	if _, err := process("p.go", src, options{}); err == nil || !strings.Contains(err.Error(), "dot import") {
+		t.Errorf("err got:%v want dot import error", err)
+	}
+}
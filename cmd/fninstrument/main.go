@@ -0,0 +1,147 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command fninstrument inserts or removes the idiomatic package fn trace
+// line 'defer fn.LogTrace()()' at the top of funcs in Go source files.
+//
+// Usage:
+//
+//	fninstrument [flags] path ...
+//
+// Each path is a Go file or a directory whose .go files are processed
+// (not recursively). By default the result is written to standard output.
+//
+// Flags:
+//
+//	-w        write result to (source) file instead of stdout
+//	-l        list files whose content would change
+//	-remove   remove previously inserted trace lines instead of inserting
+//	-pkg re   only process files whose package clause name (not import path) matches regexp re
+//	-func re  only process funcs whose name (Recv.Name for methods) matches re
+//	-tests    also process _test.go files
+//
+// Inserting is idempotent, a func already starting with the trace line is
+// left as is; removing only removes the trace line when it is the first
+// statement of a func, and the fn import when no longer used.
+// Funcs declaring the name package fn is imported as, such as a param
+// 'fn func()', are skipped. A blank import of package fn is ignored, so a
+// named import is added, while a dot import is reported as an error.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	write   = flag.Bool("w", false, "write result to (source) file instead of stdout")
+	list    = flag.Bool("l", false, "list files whose content would change")
+	remove  = flag.Bool("remove", false, "remove previously inserted trace lines")
+	pkgRe   = flag.String("pkg", "", "only process files whose package clause name (not import path) matches regexp")
+	funcRe  = flag.String("func", "", "only process funcs whose name (Recv.Name for methods) matches regexp")
+	doTests = flag.Bool("tests", false, "also process _test.go files")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: fninstrument [flags] path ...\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+	}
+	var opts options
+	opts.remove = *remove
+	var err error
+	if opts.pkg, err = regexp.Compile(*pkgRe); err != nil {
+		fatalf("bad -pkg regexp: %v", err)
+	}
+	if opts.fn, err = regexp.Compile(*funcRe); err != nil {
+		fatalf("bad -func regexp: %v", err)
+	}
+
+	exit := 0
+	for _, path := range flag.Args() {
+		files, err := goFiles(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit = 1
+			continue
+		}
+		for _, file := range files {
+			if err := processFile(file, opts); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				exit = 1
+			}
+		}
+	}
+	os.Exit(exit)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "fninstrument: "+format+"\n", args...)
+	os.Exit(2)
+}
+
+// goFiles - returns path if a file else the .go files in directory path.
+func goFiles(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+	fis, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if strings.HasSuffix(name, "_test.go") && !*doTests {
+			continue
+		}
+		files = append(files, filepath.Join(path, name))
+	}
+	return files, nil
+}
+
+func processFile(file string, opts options) error {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	res, err := process(file, src, opts)
+	if err != nil {
+		return err
+	}
+	changed := !bytes.Equal(src, res)
+	if *list && changed {
+		fmt.Println(file)
+	}
+	if *write {
+		if changed {
+			return ioutil.WriteFile(file, res, 0644)
+		}
+		return nil
+	}
+	if !*list {
+		_, err = os.Stdout.Write(res)
+	}
+	return err
+}
@@ -6,10 +6,12 @@ package fn_test
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/phcurtis/fn"
+	"github.com/phcurtis/fn/fntest"
 )
 
 func ExampleCur() {
@@ -141,3 +143,21 @@ func Example_lvlinfoshort() { // line 117
 	// Representative Output:
 	// example_test.go:117:fn_test.ExampleLvlInfoShort()
 }
+
+// Reproducible trace output using a fake Clock.
+func Example_clock() {
+	defer fn.SetPkgCfgDef(true) // restore defaults including the system Clock
+	fn.LogSetOutput(os.Stdout)
+	fn.LogSetFlags(fn.LflagsOff)
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Trbegtime | fn.Trendtime)
+	clk := fntest.NewClock(time.Date(2017, 10, 21, 13, 24, 10, 0, time.UTC), 0)
+	fn.LogSetClock(clk)
+
+	func() {
+		defer fn.LogTrace()()
+		clk.Advance(1500 * time.Millisecond)
+	}()
+	// Output:
+	// LogFN: BegTrace:fn_test.Example_clock.func1 Time:2017/10/21 13:24:10
+	// LogFN: EndTrace:fn_test.Example_clock.func1 Dur:1.5s Time:2017/10/21 13:24:11
+}
@@ -0,0 +1,169 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// FlightRecorderDef - default FlightRecorder capacity (in trace lines).
+const FlightRecorderDef = 1000
+
+// FlightRecorder - an io.Writer retaining in memory the last maxEvents
+// trace lines written to it (and optionally only those younger than maxAge)
+// so they can be dumped on demand, such as on panic or signal.
+type FlightRecorder struct {
+	mu     sync.Mutex
+	maxAge time.Duration
+	ring   []frEntry
+	head   int
+	n      int
+}
+
+type frEntry struct {
+	t    time.Time
+	line []byte
+}
+
+// NewFlightRecorder - returns a FlightRecorder holding the last maxEvents
+// lines (FlightRecorderDef if maxEvents < 1); if maxAge > 0 lines older
+// than maxAge according to the log tracing Clock are discarded as well.
+func NewFlightRecorder(maxEvents int, maxAge time.Duration) *FlightRecorder {
+	if maxEvents < 1 {
+		maxEvents = FlightRecorderDef
+	}
+	return &FlightRecorder{maxAge: maxAge, ring: make([]frEntry, maxEvents)}
+}
+
+// Write - records a copy of p, overwriting the oldest line when full.
+func (fr *FlightRecorder) Write(p []byte) (int, error) {
+	now := clockNow()
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.expire(now)
+	e := frEntry{t: now, line: append([]byte(nil), p...)}
+	if fr.n == len(fr.ring) {
+		fr.ring[fr.head] = e
+		fr.head = (fr.head + 1) % len(fr.ring)
+	} else {
+		fr.ring[(fr.head+fr.n)%len(fr.ring)] = e
+		fr.n++
+	}
+	return len(p), nil
+}
+
+// drop lines older than maxAge, caller holds fr.mu
+func (fr *FlightRecorder) expire(now time.Time) {
+	if fr.maxAge <= 0 {
+		return
+	}
+	for fr.n > 0 && now.Sub(fr.ring[fr.head].t) > fr.maxAge {
+		fr.ring[fr.head] = frEntry{}
+		fr.head = (fr.head + 1) % len(fr.ring)
+		fr.n--
+	}
+}
+
+// Len - returns number of lines currently retained.
+func (fr *FlightRecorder) Len() int {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.expire(clockNow())
+	return fr.n
+}
+
+// Reset - discards all retained lines.
+func (fr *FlightRecorder) Reset() {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	for i := range fr.ring {
+		fr.ring[i] = frEntry{}
+	}
+	fr.head, fr.n = 0, 0
+}
+
+// Dump - writes the retained lines oldest first to w.
+func (fr *FlightRecorder) Dump(w io.Writer) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.expire(clockNow())
+	for i := 0; i < fr.n; i++ {
+		if _, err := w.Write(fr.ring[(fr.head+i)%len(fr.ring)].line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var flightRec *FlightRecorder // protected by muLogt
+
+// ErrNoFlightRecorder - returned by DumpFlightRecorder when none is set.
+var ErrNoFlightRecorder = errors.New("fn: no flight recorder set")
+
+// logOutputTee - returns writer logt should use for iowr, including
+// the flight recorder when set; caller holds muLogt.
+func logOutputTee(iowr io.Writer) io.Writer {
+	if flightRec == nil {
+		return iowr
+	}
+	return recTee{rec: flightRec, w: iowr}
+}
+
+// recTee - writes to rec before w so a failing w never drops a line
+// from the flight recorder; returns the result of w.
+type recTee struct {
+	rec *FlightRecorder
+	w   io.Writer
+}
+
+func (t recTee) Write(p []byte) (int, error) {
+	t.rec.Write(p)
+	return t.w.Write(p)
+}
+
+// LogSetFlightRecorder - sets the flight recorder which receives a copy of
+// every log trace line in addition to the log output (nil removes it).
+// To keep tracing on but write nothing, combine it with LogSetOutput(ioutil.Discard).
+func LogSetFlightRecorder(fr *FlightRecorder) {
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	flightRec = fr
+	logt.SetOutput(logOutputTee(logOutputCur))
+}
+
+// LogFlightRecorder - returns the current flight recorder (nil if none).
+func LogFlightRecorder() *FlightRecorder {
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	return flightRec
+}
+
+// DumpFlightRecorder - writes the lines retained by the current
+// flight recorder to w.
+func DumpFlightRecorder(w io.Writer) error {
+	fr := LogFlightRecorder()
+	if fr == nil {
+		return ErrNoFlightRecorder
+	}
+	return fr.Dump(w)
+}
+
+// DumpFlightRecorderOnPanic - when panicking, dumps the current flight
+// recorder to w and continues panicking. It must be deferred directly.
+//
+//	Idiomatic usage at start of main or goroutine: defer fn.DumpFlightRecorderOnPanic(os.Stderr)
+func DumpFlightRecorderOnPanic(w io.Writer) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	fmt.Fprintf(w, "FlightRecorder dump on panic: %v\n", p)
+	DumpFlightRecorder(w)
+	panic(p)
+}
@@ -0,0 +1,100 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phcurtis/fn"
+)
+
+func TestFlightRecorderRing(t *testing.T) {
+	fr := fn.NewFlightRecorder(3, 0)
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(fr, "l%d\n", i)
+	}
+	var buf bytes.Buffer
+	if err := fr.Dump(&buf); err != nil {
+		t.Fatalf("Dump err:%v", err)
+	}
+	if got, want := buf.String(), "l2\nl3\nl4\n"; got != want {
+		t.Errorf("\n got:%q \nwant:%q", got, want)
+	}
+	fr.Reset()
+	if got := fr.Len(); got != 0 {
+		t.Errorf("Len after Reset got:%d want:0", got)
+	}
+}
+
+func TestFlightRecorderMaxAge(t *testing.T) {
+	fr := fn.NewFlightRecorder(0, 20*time.Millisecond)
+	fmt.Fprint(fr, "old\n")
+	time.Sleep(40 * time.Millisecond)
+	fmt.Fprint(fr, "new\n")
+	var buf bytes.Buffer
+	fr.Dump(&buf)
+	if got, want := buf.String(), "new\n"; got != want {
+		t.Errorf("\n got:%q \nwant:%q", got, want)
+	}
+}
+
+func TestLogSetFlightRecorder(t *testing.T) {
+	defer fn.LogSetFlightRecorder(nil)
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+
+	var buf bytes.Buffer
+	if err := fn.DumpFlightRecorder(&buf); err != fn.ErrNoFlightRecorder {
+		t.Errorf("err got:%v want:%v", err, fn.ErrNoFlightRecorder)
+	}
+
+	fr := fn.NewFlightRecorder(10, 0)
+	fn.LogSetFlightRecorder(fr)
+	fn.LogSetOutput(ioutil.Discard) // write nothing but keep recording
+	fn.LogSetFlags(fn.LflagsOff)
+	fn.LogTraceMsgs("m1")("m2")
+	if got := fr.Len(); got != 2 {
+		t.Fatalf("Len got:%d want:2", got)
+	}
+	fn.DumpFlightRecorder(&buf)
+	if got := buf.String(); !strings.Contains(got, fn.LbegTraceMsgsLab) ||
+		!strings.Contains(got, fn.LendTraceMsgsLab) {
+		t.Errorf("unexpected dump:%q", got)
+	}
+
+	buf.Reset()
+	func() {
+		defer func() { recover() }()
+		defer fn.DumpFlightRecorderOnPanic(&buf)
+		panic("boom")
+	}()
+	if got := buf.String(); !strings.HasPrefix(got, "FlightRecorder dump on panic: boom\n") ||
+		strings.Count(got, "\n") != 3 {
+		t.Errorf("unexpected panic dump:%q", got)
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestFlightRecorderFailingOutput(t *testing.T) {
+	defer fn.LogSetFlightRecorder(nil)
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+
+	fr := fn.NewFlightRecorder(10, 0)
+	fn.LogSetFlightRecorder(fr)
+	fn.LogSetOutput(failWriter{})
+	fn.LogSetFlags(fn.LflagsOff)
+	fn.LogTraceMsgs("m1")("m2")
+	if got := fr.Len(); got != 2 {
+		t.Errorf("Len got:%d want:2", got)
+	}
+}
@@ -10,9 +10,11 @@
 package fn
 
 import (
+	"bytes"
 	"fmt"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 )
 
@@ -42,12 +44,36 @@ const CStkEndPfix = "<EndOfCallStack:"
 
 const cStkEndPfix = CStkEndPfix + "lvlll-lvl="
 
+// pcName - returns the func name 'skip' levels above the caller of pcName
+// ("" if none). The return PC is backed up into its call instruction so
+// that a func inlined by the compiler is named rather than its caller.
+func pcName(skip int) string {
+	return pcFunc(callerPC(skip + 1))
+}
+
+// callerPC - returns the return pc 'skip' levels above the caller of
+// callerPC (0 if none), as runtime.Caller would without resolving it.
+func callerPC(skip int) uintptr {
+	var pc [1]uintptr
+	if runtime.Callers(skip+2, pc[:]) == 0 {
+		return 0
+	}
+	return pc[0]
+}
+
+// pcFunc - returns the func name of a return pc of callerPC ("" if none),
+// see pcName.
+func pcFunc(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	return runtime.FuncForPC(pc - 1).Name()
+}
+
 // low level func getting a given 'lvl' func name
 func lvlll(lvl int, nform nameform) string {
 	const baselvl = 2
-	pc := make([]uintptr, 10)
-	runtime.Callers(baselvl+lvl, pc)
-	name := runtime.FuncForPC(pc[0]).Name()
+	name := pcName(baselvl + lvl - 1)
 	if name == "" {
 		name = fmt.Sprintf(cStkEndPfix+"%d>", lvl)
 	} else {
@@ -61,6 +87,7 @@ func lvlll(lvl int, nform nameform) string {
 // Lvl - returns the func name relative to levels back on
 // caller stack it was invoked from. Use lvl=Lpar for parent func,
 // lvl=Lgpar or lvl=2 for GrandParent and so on.
+// Funcs inlined by the compiler count as levels of their own.
 func Lvl(lvl int) string {
 	return lvlll(lvl+Lpar, nfull)
 }
@@ -82,18 +109,11 @@ const (
 // adjusted according to flags value.
 func LvlInfo(lvl int, flags int) (file string, line int, name string) {
 	const baselvl = 2
-	pc := make([]uintptr, 10)
-	runtime.Callers(baselvl+lvl, pc)
-	name = runtime.FuncForPC(pc[0]).Name()
+	name = pcName(baselvl + lvl - 1)
 	if name == "" {
 		name = fmt.Sprintf(cStkEndPfix+"%d>", lvl)
 	} else {
-		if flags&Ifnbase > 0 {
-			name = filepath.Base(name)
-		}
-		if flags&Ifuncnoparens == 0 {
-			name += "()"
-		}
+		name = infoName(name, flags)
 	}
 	var ok bool
 	_, file, line, ok = runtime.Caller(baselvl + lvl - 1)
@@ -101,6 +121,22 @@ func LvlInfo(lvl int, flags int) (file string, line int, name string) {
 		file = "???"
 		line = 0
 	}
+	return infoFile(file, flags), line, name
+}
+
+// infoName - returns func name adjusted according to LvlInfo flags value.
+func infoName(name string, flags int) string {
+	if flags&Ifnbase > 0 {
+		name = filepath.Base(name)
+	}
+	if flags&Ifuncnoparens == 0 {
+		name += "()"
+	}
+	return name
+}
+
+// infoFile - returns filename adjusted according to LvlInfo flags value.
+func infoFile(file string, flags int) string {
 	if flags&Ifileshort > 0 {
 		file = filepath.Base(file)
 	} else if flags&Ifilenogps > 0 {
@@ -108,7 +144,7 @@ func LvlInfo(lvl int, flags int) (file string, line int, name string) {
 			file = file[len(gopathsrc):]
 		}
 	}
-	return file, line, name
+	return file
 }
 
 // LvlInfoStr - returns level one string containing info details,
@@ -173,3 +209,154 @@ func LvlCStk(lvl int) string {
 func CStk() string {
 	return LvlCStk(Lpar)
 }
+
+// Goid - returns the id of the current goroutine as shown in stack traces.
+func Goid() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+// CStkSepDef - default separator between func names of a call stack string.
+const CStkSepDef = "<--"
+
+// CStkOpts - options adjusting the call stack string of CStkWith and LvlCStkWith.
+type CStkOpts struct {
+	Sep       string // separator between func names, CStkSepDef if ""
+	RootFirst bool   // order outermost (root) func first instead of innermost (leaf)
+	MaxDepth  int    // max number of func names, LvlCStkMax if < 1
+	Base      bool   // filepath.Base form of func names
+	NoRuntime bool   // drop runtime package frames such as runtime.main, runtime.goexit
+	NoTesting bool   // drop testing package frames such as testing.tRunner
+	NoStdlib  bool   // drop all standard library frames (includes runtime and testing)
+	Lines     bool   // include line nums as name:line (also in StackHash)
+}
+
+// funcPkgPath - returns import path portion of a full func name.
+func funcPkgPath(name string) string {
+	i := strings.LastIndex(name, "/")
+	if j := strings.Index(name[i+1:], "."); j >= 0 {
+		return name[:i+1+j]
+	}
+	return name
+}
+
+// isStdPkg - true if package path is of the standard library, guessed
+// as done by go tooling by no dot in the first path element.
+func isStdPkg(path string) bool {
+	if path == "main" {
+		return false
+	}
+	if i := strings.Index(path, "/"); i >= 0 {
+		path = path[:i]
+	}
+	return !strings.Contains(path, ".")
+}
+
+// keep - true if func name passes the opts frame filters.
+func (opts *CStkOpts) keep(name string) bool {
+	if !opts.NoRuntime && !opts.NoTesting && !opts.NoStdlib {
+		return true
+	}
+	pkg := funcPkgPath(name)
+	switch {
+	case opts.NoStdlib && isStdPkg(pkg):
+		return false
+	case opts.NoRuntime && (pkg == "runtime" || strings.HasPrefix(pkg, "runtime/") ||
+		strings.HasPrefix(pkg, "internal/runtime/")):
+		return false
+	case opts.NoTesting && (pkg == "testing" || strings.HasPrefix(pkg, "testing/")):
+		return false
+	}
+	return true
+}
+
+// cstkFrames - returns the frames of the call stack 'skip' levels above
+// the caller of cstkFrames filtered and limited according to opts, leaf first.
+func cstkFrames(skip int, opts *CStkOpts) []runtime.Frame {
+	max := opts.MaxDepth
+	if max < 1 {
+		max = LvlCStkMax
+	}
+	pc := make([]uintptr, LvlCStkMax+1)
+	n := runtime.Callers(skip+2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	var res []runtime.Frame
+	for len(res) < max {
+		fr, more := frames.Next()
+		if fr.Function != "" && opts.keep(fr.Function) {
+			res = append(res, fr)
+		}
+		if !more {
+			break
+		}
+	}
+	return res
+}
+
+// LvlCStkWith - returns func names in call stack for a given level relative
+// to were it was invoked from, adjusted according to opts.
+// Use lvl=Lme for the invoking func, lvl=Lpar for parent func and so on.
+func LvlCStkWith(lvl int, opts CStkOpts) string {
+	return cstkString(cstkFrames(lvl+1, &opts), &opts)
+}
+
+// LvlFrameOutside - returns the frame of the first func, from the given
+// level relative to where it was invoked from and outward, whose package
+// is none of pkgs, such as the caller of a wrapper invoked via a standard
+// library package; ok is false if there is none.
+// Use lvl=Lme to start from the invoking func.
+//
+//	Example: at, _ := fn.LvlFrameOutside(fn.Lme, "net/http", "example.com/mywrapper")
+func LvlFrameOutside(lvl int, pkgs ...string) (f Frame, ok bool) {
+	for _, fr := range cstkFrames(lvl+1, &CStkOpts{}) {
+		pkg := funcPkgPath(fr.Function)
+		outside := true
+		for _, p := range pkgs {
+			if pkg == p {
+				outside = false
+				break
+			}
+		}
+		if outside {
+			return Frame{Func: fr.Function, File: fr.File, Line: fr.Line}, true
+		}
+	}
+	return Frame{}, false
+}
+
+// CStkWith - returns func names in call stack relative to where it was
+// invoked from adjusted according to opts.
+//
+//	Example: fn.CStkWith(fn.CStkOpts{Base: true, RootFirst: true, Sep: " > ", NoStdlib: true})
+func CStkWith(opts CStkOpts) string {
+	return cstkString(cstkFrames(1, &opts), &opts)
+}
+
+func cstkString(frames []runtime.Frame, opts *CStkOpts) string {
+	sep := opts.Sep
+	if sep == "" {
+		sep = CStkSepDef
+	}
+	names := make([]string, len(frames))
+	for i, fr := range frames {
+		name := fr.Function
+		if opts.Base {
+			name = filepath.Base(name)
+		}
+		if opts.Lines {
+			name += ":" + strconv.Itoa(fr.Line)
+		}
+		if opts.RootFirst {
+			names[len(frames)-1-i] = name
+		} else {
+			names[i] = name
+		}
+	}
+	return strings.Join(names, sep)
+}
@@ -130,6 +130,35 @@ func TestLvlInfo(t *testing.T) {
 	}
 }
 
+// lvlInlinable - small enough for the compiler to inline into its callers.
+func lvlInlinable(lvl int) string { return fn.Lvl(lvl) }
+
+// lvlInfoInlinable - same as lvlInlinable via LvlInfo.
+func lvlInfoInlinable(lvl int) string {
+	_, _, name := fn.LvlInfo(lvl, fn.Ifuncnoparens)
+	return name
+}
+
+func TestLvlInlined(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"me", lvlInlinable(fn.Lme), baseName + "lvlInlinable"},
+		{"par", lvlInlinable(fn.Lpar), baseName + "TestLvlInlined"},
+		{"gpar", lvlInlinable(fn.Lgpar), "testing.tRunner"},
+		{"cur", func() string { return fn.Cur() }(), baseName + "TestLvlInlined.func1"},
+		{"info-me", lvlInfoInlinable(fn.Lme), baseName + "lvlInfoInlinable"},
+		{"info-par", lvlInfoInlinable(fn.Lpar), baseName + "TestLvlInlined"},
+	}
+	for _, v := range tests {
+		if v.got != v.want {
+			t.Errorf("%s: got:%s want:%s", v.name, v.got, v.want)
+		}
+	}
+}
+
 func Test_lvlinfostrings(t *testing.T) {
 	//filenamegps := "/home/paul/go/src/"
 	filenameshort := "fn_test.go"
@@ -163,3 +192,59 @@ func Test_lvlinfostrings(t *testing.T) {
 		}
 	}
 }
+
+func TestGoid(t *testing.T) {
+	me := fn.Goid()
+	if me < 1 {
+		t.Fatalf("Goid got:%d want:>0", me)
+	}
+	ch := make(chan int64)
+	go func() { ch <- fn.Goid() }()
+	if other := <-ch; other < 1 || other == me {
+		t.Errorf("Goid other goroutine got:%d me:%d", other, me)
+	}
+}
+
+func c2(opts fn.CStkOpts) string          { return fn.CStkWith(opts) }
+func c1(opts fn.CStkOpts) string          { return c2(opts) }
+func d2(lvl int, opts fn.CStkOpts) string { return fn.LvlCStkWith(lvl, opts) }
+func d1(lvl int, opts fn.CStkOpts) string { return d2(lvl, opts) }
+
+func Test_cstkwith(t *testing.T) {
+	fns := "Test_cstkwith.func1"
+	tests := []struct {
+		name  string
+		lvl   int // -1 for CStkWith via c1 else LvlCStkWith via d1
+		opts  fn.CStkOpts
+		want  string
+		exact bool
+	}{
+		{"default", -1, fn.CStkOpts{},
+			baseName + "c2<--" + baseName + "c1<--" + baseName + fns, false},
+		{"base-sep", -1, fn.CStkOpts{Base: true, Sep: " < "},
+			pkgName + ".c2 < " + pkgName + ".c1 < " + pkgName + "." + fns, false},
+		{"maxdepth", -1, fn.CStkOpts{Base: true, MaxDepth: 2},
+			pkgName + ".c2<--" + pkgName + ".c1", true},
+		{"lvl", 1, fn.CStkOpts{Base: true, MaxDepth: 2},
+			pkgName + ".d1<--" + pkgName + "." + fns, true},
+		{"rootfirst-nostdlib", -1, fn.CStkOpts{Base: true, RootFirst: true, Sep: ">", NoStdlib: true},
+			pkgName + "." + fns + ">" + pkgName + ".c1>" + pkgName + ".c2", true},
+		{"noruntime-notesting", -1, fn.CStkOpts{Base: true, NoRuntime: true, NoTesting: true},
+			pkgName + ".c2<--" + pkgName + ".c1<--" + pkgName + "." + fns, true},
+		{"default-runtime", -1, fn.CStkOpts{Base: true},
+			pkgName + ".c2<--" + pkgName + ".c1<--" + pkgName + "." + fns + "<--testing.tRunner<--runtime.goexit", true},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			var got string
+			if v.lvl < 0 {
+				got = c1(v.opts)
+			} else {
+				got = d1(v.lvl, v.opts)
+			}
+			if (v.exact && got != v.want) || (!v.exact && !strings.HasPrefix(got, v.want)) {
+				t.Errorf("%s:\n got:%s \nwant:%s (exact:%t)\n", v.name, got, v.want, v.exact)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fnhttp - HTTP handlers for runtime control and inspection of
+// package fn tracing, similar to net/http/pprof however nothing is
+// registered on import as the config endpoint changes the running config.
+// Mount Handler on a mux only reachable by trusted clients, it serves
+// under Prefix:
+//
+//	/debug/fn/            index of the endpoints
+//	/debug/fn/config      GET the package config as JSON, POST or PUT a
+//	                      partial config (JSON or text form) to change it
+//	/debug/fn/events      server-sent events stream of live trace events
+//	/debug/fn/stats       per func stats (see fn.Trfuncstats)
+//	/debug/fn/opentraces  begun but not ended traces (see fn.Tropentraces)
+//	/debug/fn/stacks      goroutine stacks grouped by identical stacks
+//
+//	Example: debugMux.Handle(fnhttp.Prefix, fnhttp.Handler())
+//
+// Middleware and Transport log trace the requests of a server and a client.
+package fnhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+
+	"github.com/phcurtis/fn"
+)
+
+// Prefix - path prefix of the handlers.
+const Prefix = "/debug/fn/"
+
+// Handler limits.
+const (
+	ConfigBodyMax   = 64 << 10 // max bytes of a config POST/PUT body
+	EventsBufferDef = 256      // events buffered per events client before dropping
+)
+
+// Handler - returns a handler serving all the endpoints under Prefix.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(Prefix, Index)
+	mux.HandleFunc(Prefix+"config", Config)
+	mux.HandleFunc(Prefix+"events", Events)
+	mux.HandleFunc(Prefix+"stats", Stats)
+	mux.HandleFunc(Prefix+"opentraces", OpenTraces)
+	mux.HandleFunc(Prefix+"stacks", Stacks)
+	return mux
+}
+
+var endpoints = []struct{ name, desc string }{
+	{"config", "package config, POST or PUT a partial config to change it"},
+	{"events", "server-sent events of live trace events, ?filter=regexp of func names"},
+	{"stats", "per func stats (Trfuncstats), ?format=json"},
+	{"opentraces", "begun but not ended traces (Tropentraces), ?format=json"},
+	{"stacks", "goroutine stacks grouped by identical stacks, ?format=cstk|json"},
+}
+
+// Index - responds with the list of endpoints.
+func Index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != Prefix {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "fn %v debug endpoints:\n", fn.Version)
+	for _, e := range endpoints {
+		fmt.Fprintf(tw, "%s%s\t%s\n", Prefix, e.name, e.desc)
+	}
+	tw.Flush()
+}
+
+// Config - responds with the package config (see fn.PkgCfgStruct MarshalJSON).
+// A POST or PUT body in JSON or text form (see fn.PkgCfgStruct MarshalText)
+// changes the keys it includes via fn.SetPkgCfgChecked, the log output
+// is not changeable; an invalid config is rejected with status 400.
+func Config(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost, http.MethodPut:
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, ConfigBodyMax+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(body) > ConfigBodyMax {
+			http.Error(w, "config body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		p, _ := fn.PkgCfg()
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+			err = json.Unmarshal(body, p)
+		} else {
+			err = p.UnmarshalText(body)
+		}
+		if err == nil {
+			err = fn.SetPkgCfgChecked(p, nil)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p, _ := fn.PkgCfg()
+	writeJSON(w, p)
+}
+
+// Events - streams live trace events as server-sent events, one JSON
+// trace event (see fn.SinkJSON) per event, until the client disconnects.
+// Query filter is a regular expression the full func name must match.
+// Events are dropped rather than delaying tracing when the client is slow,
+// reported by a ": dropped N" comment.
+func Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	var filter func(ev *fn.TraceEvent) bool
+	if expr := r.URL.Query().Get("filter"); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter = func(ev *fn.TraceEvent) bool { return re.MatchString(ev.Func) }
+	}
+
+	ew := &eventsWriter{c: make(chan []byte, EventsBufferDef)}
+	remove := fn.LogAddSink(fn.Sink{
+		Writer:     ew,
+		Format:     fn.SinkJSON,
+		TraceFlags: fn.TrFlagsDef,
+		Filter:     filter,
+	})
+	defer remove()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	var dropped int
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line := <-ew.c:
+			if n := ew.droppedN(); n != dropped {
+				fmt.Fprintf(w, ": dropped %d\n\n", n)
+				dropped = n
+			}
+			fmt.Fprintf(w, "data: %s\n\n", bytes.TrimSuffix(line, []byte("\n")))
+			flusher.Flush()
+		}
+	}
+}
+
+// eventsWriter - sink writer handing each JSON event line to an Events
+// handler without blocking the tracing goroutine.
+type eventsWriter struct {
+	c       chan []byte
+	dropped int64 // atomic
+}
+
+func (ew *eventsWriter) Write(p []byte) (int, error) {
+	select {
+	case ew.c <- append([]byte(nil), p...):
+	default:
+		atomic.AddInt64(&ew.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// droppedN - returns the number of events dropped so far.
+func (ew *eventsWriter) droppedN() int {
+	return int(atomic.LoadInt64(&ew.dropped))
+}
+
+// Stats - responds with the per func stats (see fn.FuncStatsAll) as a
+// table or with query format=json as JSON.
+func Stats(w http.ResponseWriter, r *http.Request) {
+	stats := fn.FuncStatsAll()
+	if r.URL.Query().Get("format") == "json" {
+		writeJSON(w, stats)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fn.FprintFuncStats(w, stats)
+}
+
+// OpenTraces - responds with the open traces (see fn.OpenTraces) one per
+// line or with query format=json as JSON.
+func OpenTraces(w http.ResponseWriter, r *http.Request) {
+	ots := fn.OpenTraces()
+	if r.URL.Query().Get("format") == "json" {
+		writeJSON(w, ots)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, ot := range ots {
+		fmt.Fprintln(w, ot)
+	}
+}
+
+// Stacks - responds with the goroutine stacks grouped by identical stacks
+// (see fn.FprintStacks), with query format=cstk one line per group with
+// the func names in the form of fn.CStk, or format=json as JSON.
+func Stacks(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Query().Get("format") {
+	case "json":
+		writeJSON(w, fn.GroupStacks(fn.AllStacks()))
+	case "cstk":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, sg := range fn.GroupStacks(fn.AllStacks()) {
+			names := make([]string, len(sg.Frames))
+			for i, f := range sg.Frames {
+				names[i] = f.Func
+			}
+			fmt.Fprintf(w, "%d [%s] %s\n", len(sg.IDs), sg.State, strings.Join(names, fn.CStkSepDef))
+		}
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fn.FprintStacks(w, fn.IflagsCmn)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
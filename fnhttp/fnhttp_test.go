@@ -0,0 +1,248 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fnhttp_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/phcurtis/fn"
+	"github.com/phcurtis/fn/fnhttp"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+func post(t *testing.T, url, body string) (int, string) {
+	t.Helper()
+	resp, err := http.Post(url, "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+func TestNotRegistered(t *testing.T) {
+	req := httptest.NewRequest("GET", fnhttp.Prefix+"stats", nil)
+	if _, pattern := http.DefaultServeMux.Handler(req); pattern != "" {
+		t.Errorf("DefaultServeMux pattern got:%q want none", pattern)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	srv := httptest.NewServer(fnhttp.Handler())
+	defer srv.Close()
+	code, body := get(t, srv.URL+fnhttp.Prefix)
+	if code != http.StatusOK {
+		t.Fatalf("status got:%d", code)
+	}
+	for _, ep := range []string{"config", "events", "stats", "opentraces", "stacks"} {
+		if !strings.Contains(body, fnhttp.Prefix+ep) {
+			t.Errorf("index missing %s:\n%s", ep, body)
+		}
+	}
+	if code, _ := get(t, srv.URL+fnhttp.Prefix+"bogus"); code != http.StatusNotFound {
+		t.Errorf("bogus status got:%d want:404", code)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	srv := httptest.NewServer(fnhttp.Handler())
+	defer srv.Close()
+	url := srv.URL + fnhttp.Prefix + "config"
+
+	code, body := get(t, url)
+	var got fn.PkgCfgStruct
+	if err := json.Unmarshal([]byte(body), &got); code != http.StatusOK || err != nil {
+		t.Fatalf("GET status:%d err:%v body:%s", code, err, body)
+	}
+	if def, _ := fn.PkgCfgDef(); got != *def {
+		t.Errorf("GET got:%+v want:%+v", got, *def)
+	}
+	if !strings.Contains(body, `"trace_flags": [`) {
+		t.Errorf("GET body not symbolic:\n%s", body)
+	}
+
+	tests := []struct {
+		name, body string
+		code       int
+		check      func(p *fn.PkgCfgStruct) bool
+	}{
+		{"json", `{"trace_flags":["fnbase","begtime"],"align_func":4}`, http.StatusOK,
+			func(p *fn.PkgCfgStruct) bool {
+				return p.LogTraceFlags == fn.Trfnbase|fn.Trbegtime && p.LogAlignFunc == 4 && p.LogPrefix == fn.LogPrefixDef
+			}},
+		{"text", "prefix: \"web: \"\ntrace_filter: ^main\\.\nalign_file: 99\n", http.StatusOK,
+			func(p *fn.PkgCfgStruct) bool {
+				return p.LogPrefix == "web: " && p.LogTraceFilter == `^main\.` &&
+					p.LogAlignFile == fn.LogAlignFileMax && p.LogTraceFlags == fn.Trfnbase|fn.Trbegtime
+			}},
+		{"invalid", "trace_flags: nodur|allocs\n", http.StatusBadRequest, nil},
+		{"syntax", "bogus: 1\n", http.StatusBadRequest, nil},
+	}
+	for _, tc := range tests {
+		before, _ := fn.PkgCfg()
+		code, body := post(t, url, tc.body)
+		if code != tc.code {
+			t.Errorf("%s: status got:%d want:%d body:%s", tc.name, code, tc.code, body)
+			continue
+		}
+		after, _ := fn.PkgCfg()
+		if tc.check == nil {
+			if *after != *before {
+				t.Errorf("%s: rejected config applied got:%+v", tc.name, after)
+			}
+			continue
+		}
+		if !tc.check(after) {
+			t.Errorf("%s: unexpected config:%+v", tc.name, after)
+		}
+	}
+
+	req, _ := http.NewRequest("DELETE", url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE status got:%d want:405", resp.StatusCode)
+	}
+}
+
+func eventsTraced()   { defer fn.LogTraceMsgs("hello")("bye") }
+func eventsFiltered() { defer fn.LogTrace()() }
+
+func TestEvents(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	fn.LogSetOutput(ioutil.Discard)
+	srv := httptest.NewServer(fnhttp.Handler())
+	defer srv.Close()
+
+	if code, _ := get(t, srv.URL+fnhttp.Prefix+"events?filter=("); code != http.StatusBadRequest {
+		t.Errorf("invalid filter status got:%d want:400", code)
+	}
+
+	resp, err := http.Get(srv.URL + fnhttp.Prefix + "events?filter=eventsTraced")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type got:%q", ct)
+	}
+	rd := bufio.NewReader(resp.Body)
+	if line, _ := rd.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("first line got:%q", line)
+	}
+	rd.ReadString('\n')
+
+	eventsFiltered()
+	eventsTraced()
+	var events []map[string]interface{}
+	for len(events) < 2 {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var ev map[string]interface{}
+		if err := json.Unmarshal([]byte(line[len("data: "):]), &ev); err != nil {
+			t.Fatalf("bad event %q: %v", line, err)
+		}
+		events = append(events, ev)
+	}
+	for i, want := range []struct{ label, msg string }{{"BegTrMsg", "hello"}, {"EndTrMsg", "bye"}} {
+		if events[i]["label"] != want.label || events[i]["msg"] != want.msg ||
+			events[i]["func"] != "fnhttp_test.eventsTraced" {
+			t.Errorf("event %d unexpected:%v", i, events[i])
+		}
+	}
+}
+
+func statsTraced() { defer fn.LogTrace()() }
+
+func openBlocked(started, release chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer fn.LogTrace()()
+	close(started)
+	<-release
+}
+
+func TestStatsOpenTracesStacks(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	fn.LogSetOutput(ioutil.Discard)
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Trfuncstats | fn.Tropentraces)
+	fn.FuncStatsReset()
+	defer fn.FuncStatsReset()
+	srv := httptest.NewServer(fnhttp.Handler())
+	defer srv.Close()
+
+	statsTraced()
+	statsTraced()
+	_, body := get(t, srv.URL+fnhttp.Prefix+"stats")
+	if !strings.Contains(body, "calls") || !strings.Contains(body, "fnhttp_test.statsTraced\n") {
+		t.Errorf("stats unexpected:\n%s", body)
+	}
+	_, body = get(t, srv.URL+fnhttp.Prefix+"stats?format=json")
+	var stats []fn.FuncStats
+	if err := json.Unmarshal([]byte(body), &stats); err != nil || len(stats) != 1 || stats[0].Count != 2 {
+		t.Errorf("stats json unexpected:%s err:%v", body, err)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go openBlocked(started, release, &wg)
+	<-started
+	_, body = get(t, srv.URL+fnhttp.Prefix+"opentraces")
+	if !strings.HasPrefix(body, "fnhttp_test.openBlocked() age:") {
+		t.Errorf("opentraces unexpected:\n%s", body)
+	}
+	_, body = get(t, srv.URL+fnhttp.Prefix+"opentraces?format=json")
+	var ots []fn.OpenTrace
+	if err := json.Unmarshal([]byte(body), &ots); err != nil || len(ots) != 1 ||
+		ots[0].Func != "github.com/phcurtis/fn/fnhttp_test.openBlocked" {
+		t.Errorf("opentraces json unexpected:%s err:%v", body, err)
+	}
+
+	_, body = get(t, srv.URL+fnhttp.Prefix+"stacks")
+	if !strings.Contains(body, "goroutine") || !strings.Contains(body, "fnhttp_test.openBlocked()") {
+		t.Errorf("stacks unexpected:\n%s", body)
+	}
+	_, body = get(t, srv.URL+fnhttp.Prefix+"stacks?format=cstk")
+	if !strings.Contains(body, "[chan receive] github.com/phcurtis/fn/fnhttp_test.openBlocked") {
+		t.Errorf("stacks cstk unexpected:\n%s", body)
+	}
+	_, body = get(t, srv.URL+fnhttp.Prefix+"stacks?format=json")
+	var groups []fn.StackGroup
+	if err := json.Unmarshal([]byte(body), &groups); err != nil || len(groups) == 0 {
+		t.Errorf("stacks json unexpected err:%v", err)
+	}
+	close(release)
+	wg.Wait()
+}
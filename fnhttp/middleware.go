@@ -0,0 +1,167 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fnhttp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/phcurtis/fn"
+)
+
+// Middleware - returns a handler log tracing each request to next with a
+// fn.LogTraceCtxMsgs span, the begin line with the method and path and
+// the end line also with the status code, response bytes and duration:
+//
+//	BegTrMsg:fnhttp.Middleware.func1 GET /users
+//	EndTrMsg:fnhttp.Middleware.func1 GET /users status=200 bytes=512 Dur:1.2ms
+//
+// The span is placed in the request context so fn.LogTraceCtx calls
+// of handlers given r.Context() nest under it. A panic of next is
+// logged on the end line (attribute panic) and then continues.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := r.Method + " " + r.URL.Path
+		ctx, end := fn.LogTraceCtxMsgs(r.Context(), msg)
+		rw := &respWriter{ResponseWriter: w}
+		p := serve(next, wrap(rw), r.WithContext(ctx))
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+			if p != nil {
+				status = http.StatusInternalServerError
+			}
+		}
+		if p != nil {
+			end(msg, "status", status, "bytes", rw.bytes, "panic", fmt.Sprint(p))
+			panic(p)
+		}
+		end(msg, "status", status, "bytes", rw.bytes)
+	})
+}
+
+// serve - invokes next returning its panic value if any, so the
+// pairing end func is still called from the func that began the trace.
+func serve(next http.Handler, w http.ResponseWriter, r *http.Request) (p interface{}) {
+	defer func() { p = recover() }()
+	next.ServeHTTP(w, r)
+	return nil
+}
+
+// respWriter - records the status code and bytes written of a response.
+type respWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rw *respWriter) WriteHeader(code int) {
+	if rw.status == 0 && code >= 200 { // not informational 1xx
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *respWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap - returns the underlying writer for http.ResponseController.
+func (rw *respWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// wrap - returns rw exposing only those of http.Flusher, http.Hijacker
+// and http.Pusher its underlying writer implements, so handlers checking
+// for them with a type assertion see the same as without Middleware.
+func wrap(rw *respWriter) http.ResponseWriter {
+	var kind int
+	if _, ok := rw.ResponseWriter.(http.Flusher); ok {
+		kind |= 1
+	}
+	if _, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		kind |= 2
+	}
+	if _, ok := rw.ResponseWriter.(http.Pusher); ok {
+		kind |= 4
+	}
+	f, h, p := flusher{rw}, hijacker{rw}, pusher{rw}
+	switch kind {
+	case 1:
+		return struct {
+			*respWriter
+			flusher
+		}{rw, f}
+	case 2:
+		return struct {
+			*respWriter
+			hijacker
+		}{rw, h}
+	case 3:
+		return struct {
+			*respWriter
+			flusher
+			hijacker
+		}{rw, f, h}
+	case 4:
+		return struct {
+			*respWriter
+			pusher
+		}{rw, p}
+	case 5:
+		return struct {
+			*respWriter
+			flusher
+			pusher
+		}{rw, f, p}
+	case 6:
+		return struct {
+			*respWriter
+			hijacker
+			pusher
+		}{rw, h, p}
+	case 7:
+		return struct {
+			*respWriter
+			flusher
+			hijacker
+			pusher
+		}{rw, f, h, p}
+	}
+	return rw
+}
+
+// flusher - implements http.Flusher for a respWriter whose underlying writer does.
+type flusher struct{ rw *respWriter }
+
+func (f flusher) Flush() {
+	f.rw.ResponseWriter.(http.Flusher).Flush()
+}
+
+// hijacker - implements http.Hijacker for a respWriter whose underlying
+// writer does, a hijacked response is recorded with status 101 Switching Protocols.
+type hijacker struct{ rw *respWriter }
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := h.rw.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil && h.rw.status == 0 {
+		h.rw.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// pusher - implements http.Pusher for a respWriter whose underlying writer does.
+type pusher struct{ rw *respWriter }
+
+func (p pusher) Push(target string, opts *http.PushOptions) error {
+	return p.rw.ResponseWriter.(http.Pusher).Push(target, opts)
+}
@@ -0,0 +1,174 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fnhttp_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/phcurtis/fn"
+	"github.com/phcurtis/fn/fnhttp"
+	"github.com/phcurtis/fn/fntest"
+)
+
+func usersHandler(w http.ResponseWriter, r *http.Request) {
+	_, end := fn.LogTraceCtx(r.Context())
+	defer end()
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, "hello")
+}
+
+func TestMiddleware(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	var buf bytes.Buffer
+	fn.LogSetOutput(&buf)
+	fn.LogSetFlags(0)
+	fn.LogSetPrefix("")
+	rec := fntest.CaptureAll(t)
+
+	srv := httptest.NewServer(fnhttp.Middleware(http.HandlerFunc(usersHandler)))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/users?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	srv.Close() // handler done
+
+	evs := rec.Events()
+	if len(evs) != 4 {
+		t.Fatalf("events got:%d want:4 %+v", len(evs), evs)
+	}
+	mwBeg, hBeg, hEnd, mwEnd := evs[0], evs[1], evs[2], evs[3]
+	if mwBeg.Kind != fn.TraceBeg || mwBeg.Msg != "GET /users" || mwBeg.Func != "github.com/phcurtis/fn/fnhttp.Middleware.func1" {
+		t.Errorf("middleware begin unexpected:%+v", mwBeg)
+	}
+	if hBeg.Func != "github.com/phcurtis/fn/fnhttp_test.usersHandler" || hBeg.ParentID != mwBeg.SpanID || hEnd.SpanID != hBeg.SpanID {
+		t.Errorf("handler span not nested got beg:%+v end:%+v", hBeg, hEnd)
+	}
+	if mwEnd.Kind != fn.TraceEnd || mwEnd.SpanID != mwBeg.SpanID || mwEnd.Msg != "GET /users" ||
+		fntest.Attr(mwEnd, "status") != 201 || fntest.Attr(mwEnd, "bytes") != int64(5) || mwEnd.Dur <= 0 {
+		t.Errorf("middleware end unexpected:%+v", mwEnd)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	want := fn.LendTraceMsgsLab + "fnhttp.Middleware.func1 GET /users status=201 bytes=5 Dur:"
+	if len(lines) != 5 || !strings.HasPrefix(lines[3], want) {
+		t.Errorf("end line got:%q want prefix:%q\n%s", lines[3], want, buf.String())
+	}
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	fn.LogSetOutput(ioutil.Discard)
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Tropentraces)
+	rec := fntest.CaptureAll(t)
+
+	h := fnhttp.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("panic got:%v want:boom", r)
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/p", nil))
+	}()
+	evs := rec.Events()
+	if len(evs) != 2 || fntest.Attr(evs[1], "status") != 500 || fntest.Attr(evs[1], "panic") != "boom" {
+		t.Errorf("events unexpected:%+v", evs)
+	}
+	if n := len(fn.OpenTraces()); n != 0 {
+		t.Errorf("open traces after panic got:%d want:0", n)
+	}
+}
+
+func TestMiddlewareHijack(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	fn.LogSetOutput(ioutil.Discard)
+	rec := fntest.CaptureAll(t)
+
+	mw := fnhttp.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack err:%v", err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nhi")
+		brw.Flush()
+	}))
+	done := make(chan struct{}) // a hijacked request is not waited for by Close
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mw.ServeHTTP(w, r)
+		close(done)
+	}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	<-done
+	if string(body) != "hi" {
+		t.Errorf("body got:%q want:hi", body)
+	}
+	evs := rec.Events()
+	if len(evs) != 2 || fntest.Attr(evs[1], "status") != http.StatusSwitchingProtocols {
+		t.Errorf("events unexpected:%+v", evs)
+	}
+}
+
+// plainWriter - a http.ResponseWriter implementing none of the optional interfaces.
+type plainWriter struct{ http.ResponseWriter }
+
+// fullWriter - a http.ResponseWriter also implementing http.Hijacker and http.Pusher.
+type fullWriter struct{ *httptest.ResponseRecorder }
+
+func (fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
+func (fullWriter) Push(string, *http.PushOptions) error { return http.ErrNotSupported }
+
+func TestMiddlewareInterfaces(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	fn.LogSetOutput(ioutil.Discard)
+
+	tests := []struct {
+		name                      string
+		w                         http.ResponseWriter
+		flusher, hijacker, pusher bool
+	}{
+		{"plain", plainWriter{httptest.NewRecorder()}, false, false, false},
+		{"recorder", httptest.NewRecorder(), true, false, false},
+		{"full", fullWriter{httptest.NewRecorder()}, true, true, true},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			var fl, hj, pu bool
+			fnhttp.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, fl = w.(http.Flusher)
+				_, hj = w.(http.Hijacker)
+				_, pu = w.(http.Pusher)
+			})).ServeHTTP(v.w, httptest.NewRequest("GET", "/", nil))
+			if fl != v.flusher || hj != v.hijacker || pu != v.pusher {
+				t.Errorf("flusher,hijacker,pusher got:%v,%v,%v want:%v,%v,%v",
+					fl, hj, pu, v.flusher, v.hijacker, v.pusher)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fnhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/phcurtis/fn"
+)
+
+// pkgPath - import path of this package, skipped with net/http when
+// looking for the func that invoked the http.Client.
+const pkgPath = "github.com/phcurtis/fn/fnhttp"
+
+// Transport - an http.RoundTripper log tracing each request of Base with
+// a fn.LogTraceAtCtx span attributed to the func that invoked the
+// http.Client (the first func outside net/http and this package), the
+// begin line with the method and URL host/path and the end line also with
+// the status code (or error), retries and duration:
+//
+//	BegTrMsg:main.fetch GET example.com/users
+//	EndTrMsg:main.fetch GET example.com/users status=200 retries=0 Dur:35ms
+//
+// Retries is the number of additional connections Base got for the request,
+// as when http.Transport retries a request whose reused connection was closed.
+// With ClientTrace set the httptrace.ClientTrace phases are also logged as
+// fn.LogMsgAtCtx child events of the request span each with its duration:
+//
+//	Msg:main.fetch dns host=example.com dur=1.2ms
+//	Msg:main.fetch connect addr=93.184.216.34:443 dur=20ms
+//	Msg:main.fetch tls dur=25ms
+//	Msg:main.fetch first_byte dur=34ms
+//
+// where the first_byte duration is since the request began.
+type Transport struct {
+	Base        http.RoundTripper // http.DefaultTransport if nil
+	ClientTrace bool              // log httptrace phases as child events
+}
+
+// NewTransport - returns a Transport wrapping base with ClientTrace set.
+//
+//	Example: client := &http.Client{Transport: fnhttp.NewTransport(nil)}
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base, ClientTrace: true}
+}
+
+// RoundTrip - implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	at, _ := fn.LvlFrameOutside(fn.Lme, "net/http", pkgPath)
+	msg := req.Method + " " + req.URL.Host + req.URL.Path
+	ctx, end := fn.LogTraceAtCtx(req.Context(), at, msg)
+	ph := &phases{ctx: ctx, at: at, beg: fn.LogClock().Now(), starts: map[string]time.Time{}}
+	req = req.WithContext(httptrace.WithClientTrace(ctx, ph.clientTrace(t.ClientTrace)))
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		end(msg, "retries", ph.retries(), "err", err.Error())
+		return nil, err
+	}
+	end(msg, "status", resp.StatusCode, "retries", ph.retries())
+	return resp, nil
+}
+
+// phases - httptrace hooks state of a request, the hooks may be called
+// from other goroutines such as of a dial.
+type phases struct {
+	ctx context.Context
+	at  fn.Frame
+	beg time.Time
+
+	mu     sync.Mutex
+	conns  int                  // GetConn calls
+	starts map[string]time.Time // phase (and connect addr) -> start time
+}
+
+// clientTrace - returns the hooks counting connections and, if logging,
+// logging the phases.
+func (ph *phases) clientTrace(logging bool) *httptrace.ClientTrace {
+	ct := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			ph.mu.Lock()
+			ph.conns++
+			ph.mu.Unlock()
+		},
+	}
+	if !logging {
+		return ct
+	}
+	ct.DNSStart = func(httptrace.DNSStartInfo) { ph.start("dns") }
+	ct.DNSDone = func(info httptrace.DNSDoneInfo) {
+		ph.done("dns", "dns", info.Err)
+	}
+	ct.ConnectStart = func(network, addr string) { ph.start("connect " + addr) }
+	ct.ConnectDone = func(network, addr string, err error) {
+		ph.done("connect "+addr, "connect", err, "addr", addr)
+	}
+	ct.TLSHandshakeStart = func() { ph.start("tls") }
+	ct.TLSHandshakeDone = func(_ tls.ConnectionState, err error) {
+		ph.done("tls", "tls", err)
+	}
+	ct.GotFirstResponseByte = func() {
+		fn.LogMsgAtCtx(ph.ctx, ph.at, "first_byte", "dur", since(ph.beg))
+	}
+	return ct
+}
+
+// start - records the start time of a phase.
+func (ph *phases) start(key string) {
+	ph.mu.Lock()
+	ph.starts[key] = fn.LogClock().Now()
+	ph.mu.Unlock()
+}
+
+// done - logs phase msg with its duration since the start recorded
+// under key, its error if any and the key/value args kv.
+func (ph *phases) done(key, msg string, err error, kv ...interface{}) {
+	ph.mu.Lock()
+	beg, ok := ph.starts[key]
+	delete(ph.starts, key)
+	ph.mu.Unlock()
+	if !ok {
+		return
+	}
+	kv = append(kv, "dur", since(beg))
+	if err != nil {
+		kv = append(kv, "err", err.Error())
+	}
+	fn.LogMsgAtCtx(ph.ctx, ph.at, msg, kv...)
+}
+
+// retries - returns the number of connections got beyond the first.
+func (ph *phases) retries() int {
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+	if ph.conns < 2 {
+		return 0
+	}
+	return ph.conns - 1
+}
+
+// since - duration since t according to the package fn Clock.
+func since(t time.Time) time.Duration {
+	return fn.LogClock().Now().Sub(t).Round(time.Microsecond)
+}
@@ -0,0 +1,124 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fnhttp_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phcurtis/fn"
+	"github.com/phcurtis/fn/fnhttp"
+	"github.com/phcurtis/fn/fntest"
+)
+
+func fetch(client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func TestTransport(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	var buf bytes.Buffer
+	fn.LogSetOutput(&buf)
+	fn.LogSetFlags(0)
+	fn.LogSetPrefix("")
+	rec := fntest.CaptureAll(t)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hi")
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: fnhttp.NewTransport(srv.Client().Transport)}
+	if err := fetch(client, srv.URL+"/x?q=1"); err != nil {
+		t.Fatal(err)
+	}
+
+	const fetchFn = "github.com/phcurtis/fn/fnhttp_test.fetch"
+	msg := "GET " + strings.TrimPrefix(srv.URL, "https://") + "/x"
+	evs := rec.Events()
+	if len(evs) < 2 {
+		t.Fatalf("events got:%d %+v", len(evs), evs)
+	}
+	beg, end := evs[0], evs[len(evs)-1]
+	if beg.Kind != fn.TraceBeg || beg.Func != fetchFn || beg.Msg != msg || !strings.HasSuffix(beg.File, "transport_test.go") {
+		t.Errorf("begin unexpected:%+v", beg)
+	}
+	if end.Kind != fn.TraceEnd || end.Func != fetchFn || end.SpanID != beg.SpanID || end.File != beg.File ||
+		fntest.Attr(end, "status") != 200 || fntest.Attr(end, "retries") != 0 {
+		t.Errorf("end unexpected:%+v", end)
+	}
+	phases := map[string]bool{}
+	for _, ev := range evs[1 : len(evs)-1] {
+		if ev.Kind != fn.TraceMsg || ev.Func != fetchFn || ev.ParentID != beg.SpanID || fntest.Attr(ev, "dur") == nil {
+			t.Errorf("phase unexpected:%+v", ev)
+		}
+		phases[ev.Msg] = true
+	}
+	for _, p := range []string{"connect", "tls", "first_byte"} {
+		if !phases[p] {
+			t.Errorf("phase %s missing got:%v", p, phases)
+		}
+	}
+	want := fn.LendTraceMsgsLab + "fnhttp_test.fetch " + msg + " status=200 retries=0 Dur:"
+	if !strings.Contains(buf.String(), "\n"+want) {
+		t.Errorf("end line want prefix:%q got:\n%s", want, buf.String())
+	}
+
+	// no phases without ClientTrace, errors on the end line
+	rec.Reset()
+	client.Transport = &fnhttp.Transport{Base: srv.Client().Transport}
+	if err := fetch(client, srv.URL+"/y"); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+	if err := fetch(client, srv.URL+"/z"); err == nil {
+		t.Fatal("closed server should error")
+	}
+	evs = rec.Events()
+	if len(evs) != 4 {
+		t.Fatalf("events got:%d want:4 %+v", len(evs), evs)
+	}
+	if fntest.Attr(evs[3], "status") != nil || fntest.Attr(evs[3], "err") == nil || evs[3].Func != fetchFn {
+		t.Errorf("error end unexpected:%+v", evs[3])
+	}
+}
+
+func TestTransportClock(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	fn.LogSetOutput(ioutil.Discard)
+	fn.LogSetClock(fntest.NewClock(time.Unix(0, 0), time.Hour))
+	rec := fntest.CaptureAll(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	client := &http.Client{Transport: fnhttp.NewTransport(nil)}
+	if err := fetch(client, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for _, ev := range rec.Events() {
+		if d, ok := fntest.Attr(ev, "dur").(time.Duration); ok {
+			n++
+			if d <= 0 || d%time.Hour != 0 {
+				t.Errorf("%s dur got:%v want whole hours of the fake clock", ev.Msg, d)
+			}
+		}
+	}
+	if n == 0 {
+		t.Error("no phase durations logged")
+	}
+}
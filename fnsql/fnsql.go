@@ -0,0 +1,394 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fnsql - a database/sql/driver wrapper log tracing the Exec,
+// Query, Begin, Commit and Rollback calls of a driver with package fn,
+// each attributed to the func that invoked database/sql (the first func
+// outside database/sql and this package) and given the span of its
+// context as its parent (see fn.LogTraceCtx):
+//
+//	BegTrMsg:main.listUsers query sql="SELECT name FROM users WHERE id = ?" args=[42]
+//	EndTrMsg:main.listUsers query Dur:310µs
+//	BegTrMsg:main.addUser exec sql="INSERT INTO users(name) VALUES(?)" args=[bob]
+//	EndTrMsg:main.addUser exec rows=1 Dur:1.1ms
+//
+// Register a wrapped driver and open it by the registered name:
+//
+//	fnsql.Register("fn-sqlite3", &sqlite3.SQLiteDriver{}, fnsql.Options{Redact: true})
+//	db, err := sql.Open("fn-sqlite3", dsn)
+package fnsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/phcurtis/fn"
+)
+
+// pkgPath - import path of this package, skipped with database/sql when
+// looking for the func that invoked database/sql.
+const pkgPath = "github.com/phcurtis/fn/fnsql"
+
+// SQLMaxDef - default max bytes of the SQL text of a trace line.
+const SQLMaxDef = 200
+
+// Options - adjusts what the trace lines of a wrapped driver include.
+type Options struct {
+	SQLMax int  // max bytes of SQL text (and of args) before "...", SQLMaxDef if 0, no max if < 0
+	Redact bool // replace SQL string and number literals by ? and omit the args
+}
+
+// Register - registers a driver wrapping drv under name via sql.Register,
+// which panics if name is already registered.
+func Register(name string, drv driver.Driver, opts Options) {
+	sql.Register(name, Wrap(drv, opts))
+}
+
+// Wrap - returns a driver wrapping drv, such as for sql.OpenDB via its
+// OpenConnector.
+func Wrap(drv driver.Driver, opts Options) driver.Driver {
+	return &fnDriver{drv: drv, opts: &opts}
+}
+
+// literalRe - SQL string and number literals, and numbered placeholders
+// such as $1 or :1 kept as is by redact.
+var literalRe = regexp.MustCompile(`'(?:[^']|'')*'|[$:@?]?\b[0-9]+(?:\.[0-9]+)?\b`)
+
+// redact - returns query with its string and number literals replaced by ?.
+func redact(query string) string {
+	return literalRe.ReplaceAllStringFunc(query, func(lit string) string {
+		if strings.IndexByte("$:@?", lit[0]) >= 0 {
+			return lit
+		}
+		return "?"
+	})
+}
+
+// sqlAttrs - returns the begin attributes of query and args.
+func (opts *Options) sqlAttrs(query string, args []driver.NamedValue) []interface{} {
+	if opts.Redact {
+		return []interface{}{"sql", opts.truncate(redact(query))}
+	}
+	kv := []interface{}{"sql", opts.truncate(query)}
+	if len(args) > 0 {
+		vals := make([]interface{}, len(args))
+		for i, a := range args {
+			vals[i] = a.Value
+		}
+		kv = append(kv, "args", opts.truncate(fmt.Sprint(vals)))
+	}
+	return kv
+}
+
+// truncate - returns s truncated to opts.SQLMax bytes on a rune boundary.
+func (opts *Options) truncate(s string) string {
+	max := opts.SQLMax
+	if max == 0 {
+		max = SQLMaxDef
+	}
+	if max < 0 || len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max] + "..."
+}
+
+// caller - returns the frame of the func that invoked database/sql, or
+// a zero frame (the invoking func) if there is none as when database/sql
+// rolls back a transaction of a done context in its own goroutine.
+func caller() fn.Frame {
+	at, _ := fn.LvlFrameOutside(fn.Lpar, "database/sql", pkgPath, "runtime")
+	return at
+}
+
+// errAttrs - returns the end attributes of err if any.
+func errAttrs(err error) []interface{} {
+	if err == nil {
+		return nil
+	}
+	return []interface{}{"err", err.Error()}
+}
+
+// resultAttrs - returns the end attributes of an exec.
+func resultAttrs(res driver.Result, err error) []interface{} {
+	if err != nil {
+		return errAttrs(err)
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		return []interface{}{"rows", n}
+	}
+	return nil
+}
+
+// namedValues - returns args as driver.Values for the deprecated driver
+// interfaces, which do not support named args.
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	vals := make([]driver.Value, len(args))
+	for i, a := range args {
+		if a.Name != "" {
+			return nil, errors.New("fnsql: driver does not support the use of Named Parameters")
+		}
+		vals[i] = a.Value
+	}
+	return vals, nil
+}
+
+type fnDriver struct {
+	drv  driver.Driver
+	opts *Options
+}
+
+func (d *fnDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.drv.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &fnConn{Conn: c, opts: d.opts}, nil
+}
+
+// OpenConnector - implements driver.DriverContext.
+func (d *fnDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.drv.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &fnConnector{c: c, d: d}, nil
+	}
+	return &fnConnector{name: name, d: d}, nil
+}
+
+// fnConnector - wraps the connector of a driver.DriverContext driver, else
+// opens by name.
+type fnConnector struct {
+	c    driver.Connector
+	name string
+	d    *fnDriver
+}
+
+func (c *fnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.c == nil {
+		return c.d.Open(c.name)
+	}
+	conn, err := c.c.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &fnConn{Conn: conn, opts: c.d.opts}, nil
+}
+
+func (c *fnConnector) Driver() driver.Driver { return c.d }
+
+type fnConn struct {
+	driver.Conn
+	opts *Options
+}
+
+func (c *fnConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *fnConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var st driver.Stmt
+	var err error
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		st, err = pc.PrepareContext(ctx, query)
+	} else {
+		st, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fs := &fnStmt{Stmt: st, conn: c.Conn, query: query, opts: c.opts}
+	if _, ok := st.(driver.ColumnConverter); ok {
+		return fnStmtCC{fs}, nil
+	}
+	return fs, nil
+}
+
+func (c *fnConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fnConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	spCtx, end := fn.LogTraceAtCtx(ctx, caller(), "begin")
+	var tx driver.Tx
+	var err error
+	if bt, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = bt.BeginTx(spCtx, opts)
+	} else if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		err = errors.New("fnsql: driver does not support non-default isolation level or read-only transactions")
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	end("begin", errAttrs(err)...)
+	if err != nil {
+		return nil, err
+	}
+	return &fnTx{Tx: tx, ctx: ctx}, nil
+}
+
+// ExecContext - implements driver.ExecerContext via the driver's
+// ExecerContext or Execer, else returns driver.ErrSkip so database/sql
+// executes a prepared fnStmt.
+func (c *fnConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, okc := c.Conn.(driver.ExecerContext)
+	e, ok := c.Conn.(driver.Execer)
+	if !okc && !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, end := fn.LogTraceAtCtx(ctx, caller(), "exec", c.opts.sqlAttrs(query, args)...)
+	var res driver.Result
+	var err error
+	if okc {
+		res, err = ec.ExecContext(ctx, query, args)
+	} else {
+		var vals []driver.Value
+		if vals, err = namedValues(args); err == nil {
+			res, err = e.Exec(query, vals)
+		}
+	}
+	end("exec", resultAttrs(res, err)...)
+	return res, err
+}
+
+// QueryContext - implements driver.QueryerContext as ExecContext.
+func (c *fnConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, okc := c.Conn.(driver.QueryerContext)
+	q, ok := c.Conn.(driver.Queryer)
+	if !okc && !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, end := fn.LogTraceAtCtx(ctx, caller(), "query", c.opts.sqlAttrs(query, args)...)
+	var rows driver.Rows
+	var err error
+	if okc {
+		rows, err = qc.QueryContext(ctx, query, args)
+	} else {
+		var vals []driver.Value
+		if vals, err = namedValues(args); err == nil {
+			rows, err = q.Query(query, vals)
+		}
+	}
+	end("query", errAttrs(err)...)
+	return rows, err
+}
+
+func (c *fnConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *fnConn) ResetSession(ctx context.Context) error {
+	if sr, ok := c.Conn.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *fnConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *fnConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type fnStmt struct {
+	driver.Stmt
+	conn  driver.Conn
+	query string
+	opts  *Options
+}
+
+func (s *fnStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, end := fn.LogTraceAtCtx(ctx, caller(), "exec", s.opts.sqlAttrs(s.query, args)...)
+	var res driver.Result
+	var err error
+	if ec, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = ec.ExecContext(ctx, args)
+	} else {
+		var vals []driver.Value
+		if vals, err = namedValues(args); err == nil {
+			res, err = s.Stmt.Exec(vals)
+		}
+	}
+	end("exec", resultAttrs(res, err)...)
+	return res, err
+}
+
+func (s *fnStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, end := fn.LogTraceAtCtx(ctx, caller(), "query", s.opts.sqlAttrs(s.query, args)...)
+	var rows driver.Rows
+	var err error
+	if qc, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = qc.QueryContext(ctx, args)
+	} else {
+		var vals []driver.Value
+		if vals, err = namedValues(args); err == nil {
+			rows, err = s.Stmt.Query(vals)
+		}
+	}
+	end("query", errAttrs(err)...)
+	return rows, err
+}
+
+// CheckNamedValue - implements driver.NamedValueChecker via the one of
+// the driver's stmt or conn, as database/sql checks a stmt's first.
+func (s *fnStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	if nc, ok := s.conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// fnStmtCC - a fnStmt of a driver stmt implementing the deprecated
+// driver.ColumnConverter, which database/sql uses when present.
+type fnStmtCC struct {
+	*fnStmt
+}
+
+func (s fnStmtCC) ColumnConverter(idx int) driver.ValueConverter {
+	return s.Stmt.(driver.ColumnConverter).ColumnConverter(idx)
+}
+
+// fnTx - a transaction whose commit or rollback has the span of the
+// context it began with as its parent.
+type fnTx struct {
+	driver.Tx
+	ctx context.Context
+}
+
+func (tx *fnTx) Commit() error {
+	_, end := fn.LogTraceAtCtx(tx.ctx, caller(), "commit")
+	err := tx.Tx.Commit()
+	end("commit", errAttrs(err)...)
+	return err
+}
+
+func (tx *fnTx) Rollback() error {
+	_, end := fn.LogTraceAtCtx(tx.ctx, caller(), "rollback")
+	err := tx.Tx.Rollback()
+	end("rollback", errAttrs(err)...)
+	return err
+}
@@ -0,0 +1,287 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fnsql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/phcurtis/fn"
+	"github.com/phcurtis/fn/fnsql"
+	"github.com/phcurtis/fn/fntest"
+)
+
+// fakeDriver - in-memory driver whose exec affects one row per arg and
+// whose query returns its args as rows of one column, failing query "FAIL".
+// Conns of dsn "prepare" only support prepared stmts.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	if dsn == "prepare" {
+		return &fakeConn{}, nil
+	}
+	return &fakeCtxConn{}, nil
+}
+
+var errFake = errors.New("fake failure")
+
+type fakeConn struct{}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{query}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeCtxConn struct{ fakeConn }
+
+func (c *fakeCtxConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return exec(query, len(args))
+}
+
+func (c *fakeCtxConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	vals := make([]driver.Value, len(args))
+	for i, a := range args {
+		vals[i] = a.Value
+	}
+	return query1(query, vals)
+}
+
+func exec(query string, n int) (driver.Result, error) {
+	if query == "FAIL" {
+		return nil, errFake
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func query1(query string, vals []driver.Value) (driver.Rows, error) {
+	if query == "FAIL" {
+		return nil, errFake
+	}
+	return &fakeRows{vals: vals}, nil
+}
+
+type fakeStmt struct{ query string }
+
+func (s *fakeStmt) Close() error                                    { return nil }
+func (s *fakeStmt) NumInput() int                                   { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) { return exec(s.query, len(args)) }
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error)  { return query1(s.query, args) }
+
+type fakeRows struct {
+	vals []driver.Value
+	i    int
+}
+
+func (r *fakeRows) Columns() []string { return []string{"v"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.vals) {
+		return io.EOF
+	}
+	dest[0] = r.vals[r.i]
+	r.i++
+	return nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func init() {
+	fnsql.Register("fnsql-fake", fakeDriver{}, fnsql.Options{SQLMax: 40})
+	fnsql.Register("fnsql-fake-redact", fakeDriver{}, fnsql.Options{Redact: true})
+}
+
+func openDB(t *testing.T, name, dsn string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(name, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+const self = "github.com/phcurtis/fn/fnsql_test."
+
+func addUser(ctx context.Context, db *sql.DB, name string) (int64, error) {
+	res, err := db.ExecContext(ctx, "INSERT INTO users(name) VALUES(?)", name)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func listUsers(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM users WHERE id IN (?, ?)", "ann", "bob")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		names = append(names, s)
+	}
+	return names, rows.Err()
+}
+
+func transfer(ctx context.Context, db *sql.DB) (uint64, error) {
+	ctx, end := fn.LogTraceCtx(ctx)
+	defer end()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE accounts SET n = n - 1"); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return fn.CtxSpanID(ctx), tx.Commit()
+}
+
+type wantEv struct {
+	kind      int
+	fn, msg   string
+	attrs     map[string]interface{}
+	hasParent bool
+}
+
+func checkEvents(t *testing.T, what string, evs []fn.TraceEvent, want []wantEv) {
+	t.Helper()
+	if len(evs) != len(want) {
+		t.Fatalf("%s: events got:%d want:%d %+v", what, len(evs), len(want), evs)
+	}
+	for i, w := range want {
+		ev := evs[i]
+		if ev.Kind != w.kind || ev.Func != self+w.fn || ev.Msg != w.msg || (ev.ParentID != 0) != w.hasParent ||
+			!strings.HasSuffix(ev.File, "fnsql_test.go") {
+			t.Errorf("%s: event %d unexpected:%+v", what, i, ev)
+		}
+		for k, v := range w.attrs {
+			if got := fntest.Attr(ev, k); got != v {
+				t.Errorf("%s: event %d attr %s got:%#v want:%#v", what, i, k, got, v)
+			}
+		}
+		if w.attrs == nil && len(ev.Attrs) > 0 {
+			t.Errorf("%s: event %d unexpected attrs:%v", what, i, ev.Attrs)
+		}
+	}
+}
+
+func TestDriver(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	fn.LogSetOutput(ioutil.Discard)
+	rec := fntest.CaptureAll(t)
+	ctx := context.Background()
+
+	for _, dsn := range []string{"ctx", "prepare"} {
+		db := openDB(t, "fnsql-fake", dsn)
+		rec.Take()
+
+		if n, err := addUser(ctx, db, "bob"); n != 1 || err != nil {
+			t.Fatalf("%s: addUser got:%d,%v", dsn, n, err)
+		}
+		checkEvents(t, dsn+" exec", rec.Take(), []wantEv{
+			{fn.TraceBeg, "addUser", "exec", map[string]interface{}{"sql": "INSERT INTO users(name) VALUES(?)", "args": "[bob]"}, false},
+			{fn.TraceEnd, "addUser", "exec", map[string]interface{}{"rows": int64(1)}, false},
+		})
+
+		if names, err := listUsers(db); len(names) != 2 || err != nil {
+			t.Fatalf("%s: listUsers got:%v,%v", dsn, names, err)
+		}
+		checkEvents(t, dsn+" query", rec.Take(), []wantEv{
+			{fn.TraceBeg, "listUsers", "query", map[string]interface{}{"sql": "SELECT name FROM users WHERE id IN (?, ?...", "args": "[ann bob]"}, false},
+			{fn.TraceEnd, "listUsers", "query", nil, false},
+		})
+
+		parent, err := transfer(ctx, db)
+		if err != nil {
+			t.Fatalf("%s: transfer err:%v", dsn, err)
+		}
+		evs := rec.Take()
+		var sqlEvs []fn.TraceEvent
+		for _, ev := range evs {
+			if ev.Msg != "" {
+				if ev.ParentID != parent {
+					t.Errorf("%s: %s ParentID got:%d want:%d", dsn, ev.Msg, ev.ParentID, parent)
+				}
+				sqlEvs = append(sqlEvs, ev)
+			}
+		}
+		checkEvents(t, dsn+" tx", sqlEvs, []wantEv{
+			{fn.TraceBeg, "transfer", "begin", nil, true},
+			{fn.TraceEnd, "transfer", "begin", nil, true},
+			{fn.TraceBeg, "transfer", "exec", map[string]interface{}{"sql": "UPDATE accounts SET n = n - 1"}, true},
+			{fn.TraceEnd, "transfer", "exec", map[string]interface{}{"rows": int64(0)}, true},
+			{fn.TraceBeg, "transfer", "commit", nil, true},
+			{fn.TraceEnd, "transfer", "commit", nil, true},
+		})
+
+		if _, err := db.Exec("FAIL"); err != errFake {
+			t.Errorf("%s: FAIL err got:%v", dsn, err)
+		}
+		evs = rec.Take()
+		if len(evs) != 2 || fntest.Attr(evs[1], "err") != errFake.Error() || evs[1].Func != self+"TestDriver" {
+			t.Errorf("%s: FAIL events unexpected:%+v", dsn, evs)
+		}
+		db.Close()
+	}
+}
+
+func TestDriverRedact(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	fn.LogSetOutput(ioutil.Discard)
+	rec := fntest.CaptureAll(t)
+	db := openDB(t, "fnsql-fake-redact", "ctx")
+	defer db.Close()
+
+	if _, err := db.Exec("UPDATE t1 SET name = 'it''s', n = 4.5 WHERE id = $1 OR id = :2 OR id = 42", 7, 8); err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, "redact", rec.Take(), []wantEv{
+		{fn.TraceBeg, "TestDriverRedact", "exec", map[string]interface{}{
+			"sql": "UPDATE t1 SET name = ?, n = ? WHERE id = $1 OR id = :2 OR id = ?", "args": nil}, false},
+		{fn.TraceEnd, "TestDriverRedact", "exec", map[string]interface{}{"rows": int64(2)}, false},
+	})
+}
+
+func TestWrapOpenDB(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	fn.LogSetOutput(ioutil.Discard)
+	rec := fntest.CaptureAll(t)
+
+	drv := fnsql.Wrap(fakeDriver{}, fnsql.Options{SQLMax: -1})
+	connector, err := drv.(driver.DriverContext).OpenConnector("ctx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	long := "SELECT " + strings.Repeat("x, ", 100) + "y"
+	rows, err := db.Query(long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	evs := rec.Take()
+	if len(evs) != 2 || fntest.Attr(evs[0], "sql") != long || evs[0].Func != self+"TestWrapOpenDB" {
+		t.Errorf("events unexpected:%+v", evs)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fntest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock - a fake fn.Clock for reproducible trace times and durations.
+// Each call to Now returns the current fake time and then advances it by step.
+//
+//	Typical usage: fn.LogSetClock(fntest.NewClock(start, 0)); defer fn.LogSetClock(nil)
+type Clock struct {
+	mu   sync.Mutex
+	t    time.Time
+	step time.Duration
+}
+
+// NewClock - returns a Clock starting at start advancing step on each Now.
+func NewClock(start time.Time, step time.Duration) *Clock {
+	return &Clock{t: start, step: step}
+}
+
+// Now - returns the fake time then advances it by step.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.t
+	c.t = c.t.Add(c.step)
+	return t
+}
+
+// Advance - moves the fake time forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// Set - sets the fake time to t.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
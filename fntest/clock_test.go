@@ -0,0 +1,40 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fntest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phcurtis/fn"
+	"github.com/phcurtis/fn/fntest"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2017, 10, 21, 13, 24, 10, 0, time.UTC)
+	clk := fntest.NewClock(start, time.Millisecond)
+	if got := clk.Now(); !got.Equal(start) {
+		t.Errorf("Now got:%v want:%v", got, start)
+	}
+	clk.Advance(time.Second)
+	if got, want := clk.Now(), start.Add(time.Second+time.Millisecond); !got.Equal(want) {
+		t.Errorf("Now got:%v want:%v", got, want)
+	}
+	clk.Set(start)
+	if got := clk.Now(); !got.Equal(start) {
+		t.Errorf("Now after Set got:%v want:%v", got, start)
+	}
+}
+
+func TestClockDur(t *testing.T) {
+	defer fn.LogSetClock(nil)
+	fn.LogSetClock(fntest.NewClock(time.Date(2017, 10, 21, 0, 0, 0, 0, time.UTC), 250*time.Millisecond))
+	rec := fntest.Capture(t)
+	traced()
+	evs := rec.Events()
+	if len(evs) != 2 || evs[1].Dur != 250*time.Millisecond {
+		t.Errorf("unexpected events:%+v", evs)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fntest - test helpers for package fn, such as scoping trace
+// output to one test, forwarding it to t.Log and asserting on it.
+package fntest
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phcurtis/fn"
+)
+
+// Recorder - trace events captured for one test; see Capture.
+type Recorder struct {
+	t      testing.TB
+	mu     sync.Mutex
+	all    bool // all goroutines see CaptureAll
+	goids  map[int64]bool
+	events []fn.TraceEvent
+}
+
+var (
+	muRecs sync.Mutex
+	recs   = map[testing.TB]*Recorder{}
+)
+
+// Capture - captures the trace events of the calling goroutine (typically the
+// test goroutine) until the test ends, forwarding their text form to t.Log.
+// Events from other tests, including parallel ones, are not captured;
+// use Recorder.Tag to include goroutines started by the test.
+// The package log output is left as is.
+func Capture(t testing.TB) *Recorder {
+	t.Helper()
+	return capture(t, &Recorder{t: t, goids: map[int64]bool{fn.Goid(): true}})
+}
+
+// CaptureAll - same as Capture however captures the trace events of all
+// goroutines, such as of servers and drivers a test exercises, in the order
+// they occur. Tests using it must not run in parallel with traced tests.
+func CaptureAll(t testing.TB) *Recorder {
+	t.Helper()
+	return capture(t, &Recorder{t: t, all: true})
+}
+
+func capture(t testing.TB, r *Recorder) *Recorder {
+	remove := fn.LogAddSink(fn.Sink{
+		Writer:     tlogWriter{t},
+		LogFlags:   log.Lshortfile,
+		TraceFlags: fn.TrFlagsDef,
+		Filter:     r.filter,
+		WantGoid:   !r.all,
+	})
+	muRecs.Lock()
+	recs[t] = r
+	muRecs.Unlock()
+	t.Cleanup(func() {
+		remove()
+		muRecs.Lock()
+		delete(recs, t)
+		muRecs.Unlock()
+	})
+	return r
+}
+
+// tlogWriter - forwards each log line to t.Log.
+type tlogWriter struct{ t testing.TB }
+
+func (w tlogWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// filter - sink filter recording events of the tagged goroutines.
+func (r *Recorder) filter(ev *fn.TraceEvent) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.all && !r.goids[ev.Goid] {
+		return false
+	}
+	r.events = append(r.events, *ev)
+	return true
+}
+
+// Tag - includes the trace events of the calling goroutine in the capture,
+// nothing to do for CaptureAll.
+//
+//	Typical usage: go func() { rec.Tag(); worker() }()
+func (r *Recorder) Tag() {
+	if r.all {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.goids[fn.Goid()] = true
+}
+
+// Events - returns a copy of the events captured so far.
+func (r *Recorder) Events() []fn.TraceEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]fn.TraceEvent(nil), r.events...)
+}
+
+// Reset - discards the events captured so far.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
+
+// Take - returns the events captured so far and discards them.
+func (r *Recorder) Take() []fn.TraceEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	evs := r.events
+	r.events = nil
+	return evs
+}
+
+// Attr - returns the value of attribute key of ev, nil if none.
+func Attr(ev fn.TraceEvent, key string) interface{} {
+	for _, a := range ev.Attrs {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return nil
+}
+
+// recorder - returns the Recorder of t, failing the test if none.
+func recorder(t testing.TB) *Recorder {
+	t.Helper()
+	muRecs.Lock()
+	r := recs[t]
+	muRecs.Unlock()
+	if r == nil {
+		t.Fatal("fntest: no Capture(t) active for this test")
+	}
+	return r
+}
+
+// matchFunc - true if full func name matches name in either its
+// full or its filepath.Base form.
+func matchFunc(full, name string) bool {
+	return full == name || filepath.Base(full) == name
+}
+
+// AssertTraced - reports an error if no begin or message trace event
+// was captured for func name (full or filepath.Base form such as "pkg.Func").
+func AssertTraced(t testing.TB, name string) bool {
+	t.Helper()
+	for _, ev := range recorder(t).Events() {
+		if ev.Kind != fn.TraceEnd && matchFunc(ev.Func, name) {
+			return true
+		}
+	}
+	t.Errorf("fntest: %s was not traced", name)
+	return false
+}
+
+// AssertPaired - reports an error for each captured begin trace event
+// without a pairing end event and vice versa.
+func AssertPaired(t testing.TB) bool {
+	t.Helper()
+	open := map[uint64]fn.TraceEvent{}
+	ok := true
+	for _, ev := range recorder(t).Events() {
+		switch ev.Kind {
+		case fn.TraceBeg:
+			open[ev.SpanID] = ev
+		case fn.TraceEnd:
+			if _, found := open[ev.SpanID]; !found {
+				t.Errorf("fntest: end trace without begin %s %s:%d", ev.Func, ev.File, ev.Line)
+				ok = false
+			}
+			delete(open, ev.SpanID)
+		}
+	}
+	for _, ev := range open {
+		t.Errorf("fntest: begin trace without end %s %s:%d", ev.Func, ev.File, ev.Line)
+		ok = false
+	}
+	return ok
+}
+
+// AssertDurationUnder - reports an error if func name has no captured
+// end trace event or any of them took d or longer.
+func AssertDurationUnder(t testing.TB, name string, d time.Duration) bool {
+	t.Helper()
+	var found bool
+	ok := true
+	for _, ev := range recorder(t).Events() {
+		if ev.Kind != fn.TraceEnd || !matchFunc(ev.Func, name) {
+			continue
+		}
+		found = true
+		if ev.Dur >= d {
+			t.Errorf("fntest: %s took %v want under %v", name, ev.Dur, d)
+			ok = false
+		}
+	}
+	if !found {
+		t.Errorf("fntest: %s has no end trace", name)
+		return false
+	}
+	return ok
+}
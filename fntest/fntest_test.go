@@ -0,0 +1,99 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fntest_test
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phcurtis/fn"
+	"github.com/phcurtis/fn/fntest"
+)
+
+func init() {
+	fn.LogSetOutput(ioutil.Discard) // captured output goes to t.Log
+}
+
+func traced() {
+	defer fn.LogTrace()()
+}
+
+func unended() {
+	fn.LogTraceMsgs("never ended")
+}
+
+func TestCapture(t *testing.T) {
+	for _, name := range []string{"a", "b", "c"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			rec := fntest.Capture(t)
+			traced()
+			fntest.AssertTraced(t, "fntest_test.traced")
+			fntest.AssertTraced(t, "github.com/phcurtis/fn/fntest_test.traced")
+			fntest.AssertPaired(t)
+			fntest.AssertDurationUnder(t, "fntest_test.traced", time.Second)
+			if got := len(rec.Events()); got != 2 {
+				t.Errorf("events got:%d want:2 (other parallel tests leaked in?)", got)
+			}
+		})
+	}
+}
+
+func TestCaptureTag(t *testing.T) {
+	rec := fntest.Capture(t)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); traced() }() // not tagged
+	go func() { defer wg.Done(); rec.Tag(); traced() }()
+	wg.Wait()
+	if got := len(rec.Events()); got != 2 {
+		t.Errorf("events got:%d want:2", got)
+	}
+}
+
+func TestCaptureAll(t *testing.T) {
+	rec := fntest.CaptureAll(t)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() { defer wg.Done(); traced() }()
+	wg.Wait()
+	fn.LogTraceAttrs("k", 1)()
+	evs := rec.Take()
+	if len(evs) != 4 || evs[0].Goid != 0 || fntest.Attr(evs[2], "k") != 1 {
+		t.Errorf("events unexpected:%+v", evs)
+	}
+	if got := len(rec.Events()); got != 0 {
+		t.Errorf("events after Take got:%d want:0", got)
+	}
+}
+
+func TestAssertFailures(t *testing.T) {
+	ft := &fakeT{T: t}
+	fntest.Capture(ft)
+	unended()
+	if fntest.AssertPaired(ft) {
+		t.Error("AssertPaired should fail on an unended trace")
+	}
+	if fntest.AssertTraced(ft, "fntest_test.traced") {
+		t.Error("AssertTraced should fail for a func not traced")
+	}
+	traced()
+	if fntest.AssertDurationUnder(ft, "fntest_test.traced", 0) {
+		t.Error("AssertDurationUnder should fail for a zero limit")
+	}
+	if ft.errs != 3 {
+		t.Errorf("errors reported got:%d want:3", ft.errs)
+	}
+}
+
+// fakeT - counts errors instead of failing the test.
+type fakeT struct {
+	*testing.T
+	errs int
+}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) { f.errs++ }
@@ -0,0 +1,19 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command fnvet reports misuse of the package fn log trace API,
+// see package fnvet. It runs standalone or via go vet:
+//
+//	go install github.com/phcurtis/fn/fnvet/cmd/fnvet
+//	go vet -vettool=$(which fnvet) ./...
+package main
+
+import (
+	"github.com/phcurtis/fn/fnvet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(fnvet.Analyzer)
+}
@@ -0,0 +1,207 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fnvet - a go/analysis Analyzer reporting misuse of the package fn
+// log trace API that otherwise is only caught (or silently lost) at runtime:
+//
+//	defer fn.LogTrace()          the pairing end func is never called
+//	fn.LogTrace()                the returned pairing end func is dropped
+//	end := fn.LogTrace()         end called from a closure or passed to
+//	func() { end() }()           another func panics (begFn != endFn)
+//
+// See cmd/fnvet to run it via go vet -vettool.
+package fnvet
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const fnPath = "github.com/phcurtis/fn"
+
+// Analyzer - reports misuse of the package fn log trace API.
+var Analyzer = &analysis.Analyzer{
+	Name:     "fnvet",
+	Doc:      "report misuse of package fn log trace funcs such as defer fn.LogTrace() missing its second ()",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// beginFuncs - package fn funcs returning a pairing end func, and the
+// arguments used by a suggested fix calling that end func.
+var beginFuncs = map[string]string{
+	"LogTrace":         "()",
+	"LogCondTrace":     "()",
+	"LogTraceAttrs":    "()",
+	"LogTraceMsgs":     `("")`,
+	"LogCondTraceMsgs": `("")`,
+	"LogTraceMsgp":     "",
+	"LogCondTraceMsgp": "",
+	"LogTraceCtx":      "",
+	"LogTraceCtxMsgs":  "",
+	"LogTraceAtCtx":    "",
+}
+
+// beginCall - returns the name of the package fn begin func called by e, or "".
+func beginCall(info *types.Info, e ast.Expr) string {
+	call, ok := ast.Unparen(e).(*ast.CallExpr)
+	if !ok {
+		return ""
+	}
+	f, ok := typeutil.Callee(info, call).(*types.Func)
+	if !ok || f.Pkg() == nil || f.Pkg().Path() != fnPath {
+		return ""
+	}
+	if _, ok := beginFuncs[f.Name()]; !ok {
+		return ""
+	}
+	return f.Name()
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	// end func vars: object -> func (FuncDecl or FuncLit) it was declared in
+	endVars := map[types.Object]ast.Node{}
+
+	filter := []ast.Node{
+		(*ast.DeferStmt)(nil),
+		(*ast.GoStmt)(nil),
+		(*ast.ExprStmt)(nil),
+		(*ast.AssignStmt)(nil),
+		(*ast.ValueSpec)(nil),
+	}
+	insp.WithStack(filter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		switch n := n.(type) {
+		case *ast.DeferStmt:
+			if name := beginCall(pass.TypesInfo, n.Call); name != "" {
+				reportNotCalled(pass, n.Call, "defer", name)
+			}
+		case *ast.GoStmt:
+			if name := beginCall(pass.TypesInfo, n.Call); name != "" {
+				pass.Reportf(n.Pos(), "go fn.%s(...) drops the pairing end func, use defer fn.%s(...)%s", name, name, beginFuncs[name])
+			}
+		case *ast.ExprStmt:
+			if name := beginCall(pass.TypesInfo, n.X); name != "" {
+				reportNotCalled(pass, n.X.(*ast.CallExpr), "", name)
+			}
+		case *ast.AssignStmt:
+			if len(n.Rhs) == 1 {
+				checkAssign(pass, n.Lhs, n.Rhs[0], enclosingFunc(stack), endVars)
+			}
+		case *ast.ValueSpec:
+			if len(n.Values) == 1 {
+				lhs := make([]ast.Expr, len(n.Names))
+				for i, id := range n.Names {
+					lhs[i] = id
+				}
+				checkAssign(pass, lhs, n.Values[0], enclosingFunc(stack), endVars)
+			}
+		}
+		return true
+	})
+
+	if len(endVars) == 0 {
+		return nil, nil
+	}
+	insp.WithStack([]ast.Node{(*ast.Ident)(nil)}, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		id := n.(*ast.Ident)
+		obj := pass.TypesInfo.Uses[id]
+		declFn, ok := endVars[obj]
+		if !ok {
+			return true
+		}
+		if enclosingFunc(stack) != declFn {
+			pass.Reportf(id.Pos(), "pairing end func %s called from a different func than its begin portion, this panics at runtime", id.Name)
+			return true
+		}
+		if call, ok := stack[len(stack)-2].(*ast.CallExpr); !ok || call.Fun != id {
+			pass.Reportf(id.Pos(), "pairing end func %s escapes its func, it must only be called within the func that began the trace", id.Name)
+		}
+		return true
+	})
+	return nil, nil
+}
+
+// reportNotCalled - reports a begin call whose pairing end func is never called
+// with a suggested fix when the end func takes no pointer argument.
+func reportNotCalled(pass *analysis.Pass, call *ast.CallExpr, how, name string) {
+	args := beginFuncs[name]
+	d := analysis.Diagnostic{Pos: call.Pos(), End: call.End()}
+	if how == "defer" {
+		d.Message = "defer fn." + name + "(...) defers the begin portion, the pairing end func is never called"
+		if args != "" {
+			d.SuggestedFixes = []analysis.SuggestedFix{{
+				Message:   "call the returned pairing end func",
+				TextEdits: []analysis.TextEdit{{Pos: call.End(), End: call.End(), NewText: []byte(args)}},
+			}}
+		}
+	} else {
+		d.Message = "result of fn." + name + " is dropped, the pairing end func is never called"
+		if args != "" {
+			d.SuggestedFixes = []analysis.SuggestedFix{{
+				Message: "defer the returned pairing end func",
+				TextEdits: []analysis.TextEdit{
+					{Pos: call.Pos(), End: call.Pos(), NewText: []byte("defer ")},
+					{Pos: call.End(), End: call.End(), NewText: []byte(args)},
+				},
+			}}
+		}
+	}
+	pass.Report(d)
+}
+
+// checkAssign - records end func vars assigned from a begin call and
+// reports those assigned to the blank identifier.
+func checkAssign(pass *analysis.Pass, lhs []ast.Expr, rhs ast.Expr, fn ast.Node, endVars map[types.Object]ast.Node) {
+	name := beginCall(pass.TypesInfo, rhs)
+	if name == "" {
+		return
+	}
+	i := 0
+	if name == "LogTraceCtx" || name == "LogTraceCtxMsgs" || name == "LogTraceAtCtx" {
+		i = 1 // end func follows the returned context
+	}
+	if i >= len(lhs) {
+		return
+	}
+	id, ok := lhs[i].(*ast.Ident)
+	if !ok {
+		pass.Reportf(lhs[i].Pos(), "pairing end func of fn.%s escapes its func, it must only be called within the func that began the trace", name)
+		return
+	}
+	if id.Name == "_" {
+		pass.Reportf(id.Pos(), "pairing end func of fn.%s is discarded and never called", name)
+		return
+	}
+	obj := pass.TypesInfo.Defs[id]
+	if obj == nil {
+		obj = pass.TypesInfo.Uses[id]
+	}
+	if obj != nil {
+		endVars[obj] = fn
+	}
+}
+
+// enclosingFunc - innermost FuncDecl or FuncLit in stack.
+func enclosingFunc(stack []ast.Node) ast.Node {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch stack[i].(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return stack[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package a
+
+import (
+	"context"
+
+	"github.com/phcurtis/fn"
+)
+
+func good(ctx context.Context) {
+	defer fn.LogTrace()()
+	defer fn.LogTraceMsgs("b")("e")
+	end := fn.LogCondTrace(true)
+	end()
+	ctx, end2 := fn.LogTraceCtx(ctx)
+	defer end2()
+	func() {
+		defer fn.LogTrace()() // begin and end in the same closure is fine
+	}()
+	fn.LogCondMsg(true, "unpaired is fine")
+	_ = ctx
+}
+
+func missingCall() {
+	defer fn.LogTrace()            // want `defer fn.LogTrace\(...\) defers the begin portion`
+	defer fn.LogTraceMsgs("b")     // want `defer fn.LogTraceMsgs\(...\) defers the begin portion`
+	defer fn.LogTraceMsgp("b")     // want `defer fn.LogTraceMsgp\(...\) defers the begin portion`
+	defer fn.LogTraceAttrs("k", 1) // want `defer fn.LogTraceAttrs\(...\) defers the begin portion`
+}
+
+func dropped(ctx context.Context) {
+	fn.LogTrace()              // want `result of fn.LogTrace is dropped`
+	go fn.LogTrace()           // want `go fn.LogTrace\(...\) drops the pairing end func`
+	_ = fn.LogTrace()          // want `pairing end func of fn.LogTrace is discarded`
+	_, _ = fn.LogTraceCtx(ctx) // want `pairing end func of fn.LogTraceCtx is discarded`
+
+	_, _ = fn.LogTraceCtxMsgs(ctx, "b")           // want `pairing end func of fn.LogTraceCtxMsgs is discarded`
+	_, _ = fn.LogTraceAtCtx(ctx, fn.Frame{}, "b") // want `pairing end func of fn.LogTraceAtCtx is discarded`
+}
+
+var ends []func()
+
+func otherFunc(f func()) { f() }
+
+func escapes() func() {
+	end := fn.LogTrace()
+	defer func() {
+		end() // want `pairing end func end called from a different func`
+	}()
+	var end2 = fn.LogTrace()
+	otherFunc(end2) // want `pairing end func end2 escapes its func`
+	end3 := fn.LogTrace()
+	ends = append(ends, end3) // want `pairing end func end3 escapes its func`
+	end4 := fn.LogTrace()
+	return end4 // want `pairing end func end4 escapes its func`
+}
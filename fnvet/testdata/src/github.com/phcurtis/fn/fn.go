@@ -0,0 +1,22 @@
+// Package fn - stub of package fn log trace API for fnvet tests.
+package fn
+
+import "context"
+
+func LogTrace() func()                         { return func() {} }
+func LogCondTrace(cond bool) func()            { return func() {} }
+func LogTraceMsgs(begMsg string) func(string)  { return func(string) {} }
+func LogTraceMsgp(begMsg string) func(*string) { return func(*string) {} }
+func LogTraceAttrs(kv ...interface{}) func(...interface{}) {
+	return func(...interface{}) {}
+}
+func LogTraceCtx(ctx context.Context) (context.Context, func()) { return ctx, func() {} }
+func LogTraceCtxMsgs(ctx context.Context, begMsg string, kv ...interface{}) (context.Context, func(string, ...interface{})) {
+	return ctx, func(string, ...interface{}) {}
+}
+func LogTraceAtCtx(ctx context.Context, at Frame, begMsg string, kv ...interface{}) (context.Context, func(string, ...interface{})) {
+	return ctx, func(string, ...interface{}) {}
+}
+func LogCondMsg(cond bool, msg string) {}
+
+type Frame struct{}
@@ -0,0 +1,88 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// FuncStats - aggregated durations of the log traced calls of a func
+// ended while Trfuncstats was active.
+type FuncStats struct {
+	Func  string // full func name
+	Count int64
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+}
+
+// Mean - returns the mean duration of the calls.
+func (fs FuncStats) Mean() time.Duration {
+	if fs.Count == 0 {
+		return 0
+	}
+	return fs.Total / time.Duration(fs.Count)
+}
+
+var funcStats = map[string]*FuncStats{} // protected by muLogt
+
+// funcStatsAdd - adds a call of func name; caller holds muLogt.
+func funcStatsAdd(name string, dur time.Duration) {
+	fs := funcStats[name]
+	if fs == nil {
+		fs = &FuncStats{Func: name, Min: dur}
+		funcStats[name] = fs
+	}
+	fs.Count++
+	fs.Total += dur
+	if dur < fs.Min {
+		fs.Min = dur
+	}
+	if dur > fs.Max {
+		fs.Max = dur
+	}
+}
+
+// FuncStatsAll - returns the stats of each func ordered by decreasing
+// total duration then by func name, see Trfuncstats.
+func FuncStatsAll() []FuncStats {
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	return funcStatsAll()
+}
+
+// lower level with no mutex
+func funcStatsAll() []FuncStats {
+	res := make([]FuncStats, 0, len(funcStats))
+	for _, fs := range funcStats {
+		res = append(res, *fs)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Total != res[j].Total {
+			return res[i].Total > res[j].Total
+		}
+		return res[i].Func < res[j].Func
+	})
+	return res
+}
+
+// FuncStatsReset - discards all func stats.
+func FuncStatsReset() {
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	funcStats = map[string]*FuncStats{}
+}
+
+// FprintFuncStats - writes to w a table of stats, one line per func.
+func FprintFuncStats(w io.Writer, stats []FuncStats) {
+	fmt.Fprintf(w, "%8s %12s %12s %12s %12s %s\n", "calls", "total", "mean", "min", "max", "func")
+	for _, fs := range stats {
+		fmt.Fprintf(w, "%8d %12v %12v %12v %12v %s\n",
+			fs.Count, fs.Total, fs.Mean(), fs.Min, fs.Max, fs.Func)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phcurtis/fn"
+	"github.com/phcurtis/fn/fntest"
+)
+
+func statsSlow(clk *fntest.Clock, d time.Duration) {
+	defer fn.LogTrace()()
+	clk.Advance(d)
+}
+
+func statsFast(clk *fntest.Clock) {
+	defer fn.LogTraceMsgs("beg")("end")
+	clk.Advance(time.Millisecond)
+}
+
+func TestFuncStats(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	fn.LogSetOutput(ioutil.Discard)
+	clk := fntest.NewClock(time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC), 0)
+	fn.LogSetClock(clk)
+	fn.FuncStatsReset()
+
+	statsFast(clk) // not counted
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Trfuncstats)
+	statsSlow(clk, 10*time.Millisecond)
+	statsSlow(clk, 30*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		statsFast(clk)
+	}
+
+	pfx := "github.com/phcurtis/" + pkgName
+	want := []fn.FuncStats{
+		{Func: pfx + ".statsSlow", Count: 2, Total: 40 * time.Millisecond,
+			Min: 10 * time.Millisecond, Max: 30 * time.Millisecond},
+		{Func: pfx + ".statsFast", Count: 3, Total: 3 * time.Millisecond,
+			Min: time.Millisecond, Max: time.Millisecond},
+	}
+	got := fn.FuncStatsAll()
+	if len(got) != len(want) {
+		t.Fatalf("got:%+v\nwant:%+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("[%d]\n got:%+v\nwant:%+v", i, got[i], want[i])
+		}
+	}
+	if m := got[0].Mean(); m != 20*time.Millisecond {
+		t.Errorf("Mean got:%v want:20ms", m)
+	}
+
+	var buf bytes.Buffer
+	fn.FprintFuncStats(&buf, got)
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(strings.Fields(lines[0])[0], "calls") ||
+		strings.Join(strings.Fields(lines[1]), " ") != "2 40ms 20ms 10ms 30ms "+pfx+".statsSlow" {
+		t.Errorf("FprintFuncStats unexpected:\n%s", buf.String())
+	}
+
+	fn.FuncStatsReset()
+	if n := len(fn.FuncStatsAll()); n != 0 {
+		t.Errorf("after reset got:%d want:0", n)
+	}
+}
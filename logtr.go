@@ -5,12 +5,15 @@
 package fn
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"path/filepath"
 	"runtime"
+	"runtime/trace"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,33 +25,48 @@ func strMinWidth(str string, width int) string {
 	return str
 }
 
-// fn log trace but may first need to find appropriate filename and line num
-func helplt(lvl int, msg, reffile, reflnum string) (newReffile, newReflnum string) {
-	var filenlr string
+// trSpan - state carried from the begin portion of a log trace
+// to its pairing end func.
+type trSpan struct {
+	id      uint64
+	begTime time.Time
+	begFn   string
+	begFile string
+	begLine int
+	reffile string // begin filename reference when log flags include filename
+	reflnum string // begin line num reference when log flags include filename
+	res     resSnap
+
+	goid       int64           // set when labeled or tracked
+	labeled    bool            // pprof labels applied see Trpproflabels
+	tracked    bool            // in openSpans see Tropentraces
+	pairFn     string          // func to call the end func when begFn is attributed see LogTraceAtCtx
+	filtered   bool            // func excluded by trace filter, nothing logged
+	prevLabels context.Context // pprof labels context prior to labeling
+	prevOwn    bool            // prevLabels was set by package fn
+	begPC      uintptr         // return pc of the begin call until resolved by begPos
+
+	parentID uint64          // id of enclosing span from context
+	ctx      context.Context // begin ctx, with runtime/trace task if any
+	task     *trace.Task     // see Trruntrace
+	region   *trace.Region   // see Trruntrace
+}
 
+var spanSeq uint64 // last assigned span id (atomic)
+
+// fn log trace the event to logt and sinks, when log flags include filename
+// also returns the filename and line num for a later begin reference;
+// caller holds muLogt.
+func helplt(ev *TraceEvent, reffile, reflnum string) (newReffile, newReflnum string) {
 	// get original [current] log flags
 	orgflags := logt.Flags()
 	sl := log.Lshortfile | log.Llongfile
 	lfn := orgflags & sl
 
-	var ref string
-
 	// if log flags are including filename
 	if lfn > 0 {
-		_, file, line, _ := runtime.Caller(lvl)
-		linenum := fmt.Sprintf(":%d", line)
-		if orgflags&log.Lshortfile > 0 {
-			file = filepath.Base(file)
-		} else {
-			// log.Llongfile
-			if logTraceFlags&Trfilenogps > 0 {
-				if strings.HasPrefix(file, gopathsrc) {
-					file = file[len(gopathsrc):]
-				}
-			}
-		}
-		newReffile = filepath.Base(file)
-		newReflnum = linenum
+		newReffile = filepath.Base(ev.File)
+		newReflnum = fmt.Sprintf(":%d", ev.Line)
 
 		// seems if this is true there is a problem elsewhere
 		// as in a weird invocation end portion of log trace,
@@ -58,28 +76,71 @@ func helplt(lvl int, msg, reffile, reflnum string) (newReffile, newReflnum strin
 			logt.Panic(errors.New("reffile:" + reffile + " != newReffile:" + newReffile))
 		}
 
-		if reffile != "" && logTraceFlags&Trfnobegref == 0 {
-			if logTraceFlags&Trfbegrefincfile > 0 {
-				ref = "<" + reffile + reflnum + ">"
-			} else {
-				ref = "<" + reflnum + ">"
-			}
-		}
-
-		filenlr = file + linenum + ref + " "
-		filenlr = strMinWidth(filenlr, logAlignFile)
-
 		// set log flags not to include filename
 		logt.SetFlags(orgflags &^ sl)
 	}
-	logt.Printf("%s%s", filenlr, msg)
+	logt.Print(ev.text(orgflags, logTraceFlags, logAlignFile, logAlignFunc, reffile, reflnum))
 	if lfn > 0 {
 		// restore log flags
 		logt.SetFlags(orgflags)
 	}
+	if len(sinks) > 0 {
+		sinksWrite(ev)
+	}
 	return newReffile, newReflnum
 }
 
+// text - returns the trace line text that follows what log.Logger adds
+// adjusted according to the passed log flags, trace flags and alignments.
+func (ev *TraceEvent) text(lflags, trflags, alignFile, alignFunc int, reffile, reflnum string) string {
+	var filenlr string
+	if lflags&(log.Lshortfile|log.Llongfile) > 0 {
+		file := ev.File
+		if lflags&log.Lshortfile > 0 {
+			file = filepath.Base(file)
+		} else {
+			// log.Llongfile
+			if trflags&Trfilenogps > 0 {
+				if strings.HasPrefix(file, gopathsrc) {
+					file = file[len(gopathsrc):]
+				}
+			}
+		}
+		var ref string
+		if reffile != "" && trflags&Trfnobegref == 0 {
+			if trflags&Trfbegrefincfile > 0 {
+				ref = "<" + reffile + reflnum + ">"
+			} else {
+				ref = "<" + reflnum + ">"
+			}
+		}
+		filenlr = strMinWidth(fmt.Sprintf("%s:%d%s ", file, ev.Line, ref), alignFile)
+	}
+
+	str := ev.Func
+	if trflags&Trfnbase > 0 {
+		str = filepath.Base(str)
+	}
+	str = ev.Label + strMinWidth(str, alignFunc)
+	if ev.Msg != "" {
+		str += " " + ev.Msg
+	}
+	str += attrsStr(ev.Attrs)
+
+	if ev.Kind == TraceEnd {
+		if trflags&Trnodur == 0 {
+			str += " Dur:" + ev.Dur.Round(time.Microsecond).String()
+		}
+		if trflags&Trendtime > 0 {
+			str += formatTime(ev.Time, trflags&Trmicroseconds > 0, " Time:")
+		}
+		str += ev.Res.text(trflags)
+	} else if trflags&Trbegtime > 0 {
+		str += formatTime(ev.Time, trflags&Trmicroseconds > 0, " Time:")
+	}
+	return filenlr + str
+}
+
 func formatTime(t time.Time, microseconds bool, msg string) string {
 	yr, mon, dy := t.Date()
 	hr, min, sec := t.Clock()
@@ -90,69 +151,145 @@ func formatTime(t time.Time, microseconds bool, msg string) string {
 	return fmt.Sprintf("%s%d/%02d/%02d %02d:%02d:%02d%s", msg, yr, mon, dy, hr, min, sec, micro)
 }
 
-func helpltend(lvladj int, trlabel string, start time.Time, begFn, reffile, reflnum, endMsg string) {
-	endTime := time.Now()
-	endFn := Lvl(Lgpar + lvladj)
-	if begFn != endFn {
+func helpltend(lvladj int, trlabel string, sp *trSpan, endMsg string, attrs ...Attr) {
+	if sp.filtered {
+		return
+	}
+	endTime := clockNow()
+	if sp.tracked {
+		openUntrack(sp)
+	}
+	endPC := callerPC(2 + lvladj)
+	endFn := pcFunc(endPC)
+	pairFn := sp.begFn
+	if sp.pairFn != "" {
+		pairFn = sp.pairFn
+	}
+	if pairFn != endFn {
 		if strings.Contains(CStk(), "<--runtime.gopanic") {
 			logt.Println("GOPANIC DETECTED --exiting '"+trlabel+"'(helpltend)>CStk:", CStk())
-			logt.Println("begFn:"+begFn+" != endFn:"+endFn, " reffile:", reffile, " reflnum", reflnum, "\n\n ")
+			logt.Println("begFn:"+pairFn+" != endFn:"+endFn, " reffile:", sp.reffile, " reflnum", sp.reflnum, "\n\n ")
 			return
 		}
 		// if Idiomatic usage of LogTrace and LogTraceMsgs then should not have a panic.
 		err := fmt.Sprintf("begFn != endFn\n begFn:%s\n endFn:%s\n  Cstk:%s \n"+
 			"Panic probable cause due to end trace pairing return portion called from different func",
-			begFn, endFn, CStk())
+			pairFn, endFn, CStk())
 		logt.Panic(errors.New(err)) // see todo above
 	}
-	if endMsg != "" {
-		endMsg = " " + endMsg
+	ev := &TraceEvent{
+		Kind:     TraceEnd,
+		Label:    trlabel,
+		Func:     sp.begFn,
+		Msg:      endMsg,
+		Attrs:    attrs,
+		Time:     endTime,
+		Dur:      endTime.Sub(sp.begTime),
+		SpanID:   sp.id,
+		ParentID: sp.parentID,
 	}
-	var str string
 
 	muLogt.Lock()
 	defer muLogt.Unlock()
-
-	if logTraceFlags&Trfnbase > 0 {
-		str = filepath.Base(endFn)
-	} else {
-		str = endFn
+	if posNeeded() {
+		ev.BegFile, ev.BegLine = sp.begPos()
+		ev.File, ev.Line = ev.BegFile, ev.BegLine
+		if sp.pairFn == "" {
+			ev.File, ev.Line = pcFileLine(endPC)
+		}
 	}
+	ev.Res = sp.res.delta()
+	helplt(ev, sp.reffile, sp.reflnum)
+	if logTraceFlags&Trfuncstats > 0 {
+		funcStatsAdd(sp.begFn, ev.Dur)
+	}
+	if sp.labeled {
+		labelsEnd(sp)
+	}
+	runtraceEnd(sp)
+}
 
-	str = strMinWidth(str, logAlignFunc) + endMsg
+func helpltbeg(ctx context.Context, lvladj int, trlabel string, begMsg string, attrs ...Attr) *trSpan {
+	sp := &trSpan{begTime: clockNow()}
+	sp.begPC = callerPC(2 + lvladj)
+	sp.begFn = pcFunc(sp.begPC)
+	return helpltbegSp(ctx, sp, trlabel, begMsg, attrs...)
+}
 
-	if logTraceFlags&Trnodur == 0 {
-		str += " Dur:" + endTime.Sub(start).Round(time.Microsecond).String()
+// pcFileLine - returns the file and line of the call a return pc of
+// callerPC returns to ("", 0 if none).
+func pcFileLine(pc uintptr) (string, int) {
+	if pc == 0 {
+		return "", 0
 	}
-	if logTraceFlags&Trendtime > 0 {
-		str += formatTime(endTime, logTraceFlags&Trmicroseconds > 0, " Time:")
-	}
-	helplt(3+lvladj, trlabel+str, reffile, reflnum)
+	fr, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return fr.File, fr.Line
 }
 
-func helpltbeg(lvladj int, trlabel string, begMsg string) (begTime time.Time, begFn, reffile, reflnum string) {
-	begTime = time.Now()
-	begFn = Lvl(Lgpar + lvladj)
-	if begMsg != "" {
-		begMsg = " " + begMsg
+// begPos - returns the begin file and line of sp resolving its begPC
+// on first use; caller holds muLogt.
+func (sp *trSpan) begPos() (string, int) {
+	if sp.begPC != 0 {
+		sp.begFile, sp.begLine = pcFileLine(sp.begPC)
+		sp.begPC = 0
 	}
-	var str string
-	muLogt.Lock()
-	defer muLogt.Unlock()
+	return sp.begFile, sp.begLine
+}
 
-	if logTraceFlags&Trfnbase > 0 {
-		str = filepath.Base(begFn)
+// posNeeded - reports whether trace events need their file and line, as
+// the log flags include filename or sinks are set; caller holds muLogt.
+func posNeeded() bool {
+	return logt.Flags()&(log.Lshortfile|log.Llongfile) > 0 || len(sinks) > 0
+}
+
+// helpltbegSp - logs the begin portion of span sp whose begin time, func
+// and file line (or begPC) are already set, see helpltbeg.
+func helpltbegSp(ctx context.Context, sp *trSpan, trlabel string, begMsg string, attrs ...Attr) *trSpan {
+	ev := &TraceEvent{
+		Kind:  TraceBeg,
+		Label: trlabel,
+		Func:  sp.begFn,
+		Msg:   begMsg,
+		Attrs: attrs,
+		Time:  sp.begTime,
+	}
+	if trlabel == LmsgLab {
+		ev.Kind = TraceMsg
 	} else {
-		str = begFn
+		sp.id = atomic.AddUint64(&spanSeq, 1)
+		ev.SpanID = sp.id
 	}
+	if ctx != nil {
+		if p, ok := ctx.Value(spanCtxKey{}).(*trSpan); ok {
+			sp.parentID = p.id
+			ev.ParentID = p.id
+		}
+	}
+	sp.ctx = ctx
 
-	str = strMinWidth(str, logAlignFunc) + begMsg
-
-	if logTraceFlags&Trbegtime > 0 {
-		str += formatTime(begTime, logTraceFlags&Trmicroseconds > 0, " Time:")
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	if logTraceFilter != nil && !logTraceFilter.MatchString(sp.begFn) {
+		sp.filtered = true
+		return sp
+	}
+	if posNeeded() {
+		ev.File, ev.Line = sp.begPos()
+	}
+	sp.reffile, sp.reflnum = helplt(ev, "", "")
+	if ev.Kind == TraceBeg && logTraceFlags&Trruntrace > 0 {
+		runtraceBeg(sp, logTraceFlags)
+	}
+	if ev.Kind == TraceBeg && logTraceFlags&Trpproflabels > 0 {
+		labelsBeg(sp, logTraceFlags)
 	}
-	reffile, reflnum = helplt(3, trlabel+str, "", "")
-	return begTime, begFn, reffile, reflnum
+	if ev.Kind == TraceBeg && logTraceFlags&Tropentraces > 0 {
+		openTrack(sp)
+	}
+	if ev.Kind == TraceBeg && logTraceFlags&(Trallocs|Trgoroutines) > 0 {
+		sp.res = readRes(logTraceFlags) // after logging so its cost is excluded
+	}
+	return sp
 }
 
 // LogTrace - log the begin tracing portion of the current function name
@@ -171,9 +308,9 @@ func LogTrace() func() {
 	}
 	muLogt.Unlock()
 
-	begTime, begFn, reffile, reflnum := helpltbeg(0, LbegTraceLab, "")
+	sp := helpltbeg(nil, 0, LbegTraceLab, "")
 	return func() {
-		helpltend(0, LendTraceLab, begTime, begFn, reffile, reflnum, "")
+		helpltend(0, LendTraceLab, sp, "")
 	}
 }
 
@@ -191,9 +328,9 @@ func LogCondTrace(cond bool) func() {
 	}
 	muLogt.Unlock()
 
-	begTime, begFn, reffile, reflnum := helpltbeg(0, LbegTraceLab, "")
+	sp := helpltbeg(nil, 0, LbegTraceLab, "")
 	return func() {
-		helpltend(0, LendTraceLab, begTime, begFn, reffile, reflnum, "")
+		helpltend(0, LendTraceLab, sp, "")
 	}
 }
 
@@ -213,7 +350,7 @@ func LogCondMsg(cond bool, msg string) {
 	}
 	muLogt.Unlock()
 
-	helpltbeg(0, LmsgLab, msg)
+	helpltbeg(nil, 0, LmsgLab, msg)
 }
 
 // LogCondTraceMsgs - conditional version of LogTraceMsgs.
@@ -230,9 +367,9 @@ func LogCondTraceMsgs(cond bool, begMsg string) func(endMsg string) {
 	}
 	muLogt.Unlock()
 
-	begTime, begFn, reffile, reflnum := helpltbeg(0, LbegTraceMsgsLab, begMsg)
+	sp := helpltbeg(nil, 0, LbegTraceMsgsLab, begMsg)
 	return func(endMsg string) {
-		helpltend(0, LendTraceMsgsLab, begTime, begFn, reffile, reflnum, endMsg)
+		helpltend(0, LendTraceMsgsLab, sp, endMsg)
 	}
 }
 
@@ -254,9 +391,9 @@ func LogTraceMsgs(begMsg string) func(endMsg string) {
 	}
 	muLogt.Unlock()
 
-	begTime, begFn, reffile, reflnum := helpltbeg(0, LbegTraceMsgsLab, begMsg)
+	sp := helpltbeg(nil, 0, LbegTraceMsgsLab, begMsg)
 	return func(endMsg string) {
-		helpltend(0, LendTraceMsgsLab, begTime, begFn, reffile, reflnum, endMsg)
+		helpltend(0, LendTraceMsgsLab, sp, endMsg)
 	}
 }
 
@@ -269,9 +406,9 @@ func LogTraceMsgp(begMsg string) func(endMsg *string) {
 	}
 	muLogt.Unlock()
 
-	begTime, begFn, reffile, reflnum := helpltbeg(0, LbegTraceMsgpLab, begMsg)
+	sp := helpltbeg(nil, 0, LbegTraceMsgpLab, begMsg)
 	return func(endMsg *string) {
-		helpltend(0, LendTraceMsgpLab, begTime, begFn, reffile, reflnum, *endMsg)
+		helpltend(0, LendTraceMsgpLab, sp, *endMsg)
 	}
 }
 
@@ -288,8 +425,130 @@ func LogCondTraceMsgp(cond bool, begMsg string) func(endMsg *string) {
 	}
 	muLogt.Unlock()
 
-	begTime, begFn, reffile, reflnum := helpltbeg(0, LbegTraceMsgpLab, begMsg)
+	sp := helpltbeg(nil, 0, LbegTraceMsgpLab, begMsg)
 	return func(endMsg *string) {
-		helpltend(0, LendTraceMsgpLab, begTime, begFn, reffile, reflnum, *endMsg)
+		helpltend(0, LendTraceMsgpLab, sp, *endMsg)
+	}
+}
+
+// LogTraceAttrs - same as LogTrace however logs key/value attributes
+// after funcname on both the begin and the pairing end func.
+// Note the end attributes are evaluated when the defer statement is
+// executed, pass a func() interface{} value if they depend on work done
+// in the func as it is called by the end func (see Attrs).
+//
+//	kv - alternating key, value args (or Attr values) see Attrs.
+//	Idiomatic usage at func start:
+//	    defer fn.LogTraceAttrs("userID", id)("bytes", func() interface{} { return n })
+func LogTraceAttrs(kv ...interface{}) func(kv ...interface{}) {
+	muLogt.Lock()
+	if logTraceFlags&Trlogignore > 0 {
+		muLogt.Unlock()
+		return func(...interface{}) {}
+	}
+	muLogt.Unlock()
+
+	sp := helpltbeg(nil, 0, LbegTraceAttrLab, "", Attrs(kv...)...)
+	return func(kv ...interface{}) {
+		helpltend(0, LendTraceAttrLab, sp, "", Attrs(kv...)...)
+	}
+}
+
+// LogTraceCtx - same as LogTrace however also returns a context derived
+// from ctx carrying the begun span, so LogTraceCtx calls given that context
+// record the span as their parent (see TraceEvent.ParentID), and with
+// Trruntrace a runtime/trace task named by the func.
+//
+//	Idiomatic usage at func start: ctx, end := fn.LogTraceCtx(ctx); defer end()
+func LogTraceCtx(ctx context.Context) (context.Context, func()) {
+	muLogt.Lock()
+	if logTraceFlags&Trlogignore > 0 {
+		muLogt.Unlock()
+		return ctx, func() {}
+	}
+	muLogt.Unlock()
+
+	sp := helpltbeg(ctx, 0, LbegTraceLab, "")
+	return context.WithValue(sp.ctx, spanCtxKey{}, sp), func() {
+		helpltend(0, LendTraceLab, sp, "")
+	}
+}
+
+// LogTraceCtxMsgs - same as LogTraceCtx however with begin and end messages
+// as LogTraceMsgs, and key/value attributes on both as LogTraceAttrs.
+//
+//	Idiomatic usage: ctx, end := fn.LogTraceCtxMsgs(ctx, "begMsg", "id", id); defer end("endMsg")
+func LogTraceCtxMsgs(ctx context.Context, begMsg string, kv ...interface{}) (context.Context, func(endMsg string, kv ...interface{})) {
+	muLogt.Lock()
+	if logTraceFlags&Trlogignore > 0 {
+		muLogt.Unlock()
+		return ctx, func(string, ...interface{}) {}
+	}
+	muLogt.Unlock()
+
+	sp := helpltbeg(ctx, 0, LbegTraceMsgsLab, begMsg, Attrs(kv...)...)
+	return context.WithValue(sp.ctx, spanCtxKey{}, sp), func(endMsg string, kv ...interface{}) {
+		helpltend(0, LendTraceMsgsLab, sp, endMsg, Attrs(kv...)...)
+	}
+}
+
+// LogTraceAtCtx - same as LogTraceCtxMsgs however the trace is attributed
+// to the func of frame at instead of the invoking func, for wrappers such as
+// an http.RoundTripper tracing on behalf of their callers (see LvlFrameOutside).
+// A zero frame attributes it to the invoking func. The pairing end func
+// must still be called within the func that invoked LogTraceAtCtx.
+//
+//	Idiomatic usage: at, _ := fn.LvlFrameOutside(fn.Lme, "net/http")
+//	                 ctx, end := fn.LogTraceAtCtx(ctx, at, "begMsg"); defer end("endMsg")
+func LogTraceAtCtx(ctx context.Context, at Frame, begMsg string, kv ...interface{}) (context.Context, func(endMsg string, kv ...interface{})) {
+	muLogt.Lock()
+	if logTraceFlags&Trlogignore > 0 {
+		muLogt.Unlock()
+		return ctx, func(string, ...interface{}) {}
+	}
+	muLogt.Unlock()
+
+	sp := &trSpan{begTime: clockNow()}
+	sp.begFn = Lvl(Lpar)
+	if at.Func != "" {
+		sp.pairFn = sp.begFn
+		sp.begFn, sp.begFile, sp.begLine = at.Func, at.File, at.Line
+	} else {
+		sp.begPC = callerPC(1)
+	}
+	sp = helpltbegSp(ctx, sp, LbegTraceMsgsLab, begMsg, Attrs(kv...)...)
+	return context.WithValue(sp.ctx, spanCtxKey{}, sp), func(endMsg string, kv ...interface{}) {
+		helpltend(0, LendTraceMsgsLab, sp, endMsg, Attrs(kv...)...)
+	}
+}
+
+// LogMsgAtCtx - logs a one line 'Msg:' attributed to the func of frame at
+// (the invoking func if a zero frame) with the span carried by ctx if any
+// as its parent, such as a phase of the span begun by LogTraceAtCtx.
+func LogMsgAtCtx(ctx context.Context, at Frame, msg string, kv ...interface{}) {
+	muLogt.Lock()
+	if logTraceFlags&Trlogignore > 0 {
+		muLogt.Unlock()
+		return
+	}
+	muLogt.Unlock()
+
+	sp := &trSpan{begTime: clockNow(), begFn: at.Func, begFile: at.File, begLine: at.Line}
+	if at.Func == "" {
+		sp.begFn = Lvl(Lpar)
+		sp.begPC = callerPC(1)
+	}
+	helpltbegSp(ctx, sp, LmsgLab, msg, Attrs(kv...)...)
+}
+
+// spanCtxKey - context key of the *trSpan begun by LogTraceCtx, LogTraceCtxMsgs or LogTraceAtCtx.
+type spanCtxKey struct{}
+
+// CtxSpanID - returns the id of the span carried by ctx (see LogTraceCtx)
+// or 0 if none.
+func CtxSpanID(ctx context.Context) uint64 {
+	if sp, ok := ctx.Value(spanCtxKey{}).(*trSpan); ok {
+		return sp.id
 	}
+	return 0
 }
@@ -6,14 +6,17 @@ package fn_test
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/phcurtis/fn"
+	"github.com/phcurtis/fn/fntest"
 )
 
 func Test_logtr_panic1(t *testing.T) {
@@ -331,3 +334,89 @@ func Test_logfuncs(t *testing.T) {
 		})
 	}
 }
+
+func frameOutside(lvl int, pkgs ...string) (fn.Frame, bool) {
+	return fn.LvlFrameOutside(lvl, pkgs...)
+}
+
+func TestLvlFrameOutside(t *testing.T) {
+	const self = "github.com/phcurtis/fn_test"
+	tests := []struct {
+		name string
+		lvl  int
+		pkgs []string
+		want string
+		ok   bool
+	}{
+		{"me", fn.Lme, nil, self + ".frameOutside", true},
+		{"par", fn.Lpar, []string{"bogus"}, self + ".TestLvlFrameOutside", true},
+		{"outside", fn.Lme, []string{self}, "testing.tRunner", true},
+		{"outside-lvl", fn.Lpar, []string{self, "testing"}, "runtime.goexit", true},
+		{"none", fn.Lme, []string{self, "testing", "runtime"}, "", false},
+	}
+	for _, v := range tests {
+		got, ok := frameOutside(v.lvl, v.pkgs...)
+		if got.Func != v.want || ok != v.ok {
+			t.Errorf("%s: got:%q,%t want:%q,%t", v.name, got.Func, ok, v.want, v.ok)
+		}
+	}
+	if got, _ := frameOutside(fn.Lme); !strings.HasSuffix(got.File, "logtr_test.go") || got.Line == 0 {
+		t.Errorf("frame file line got:%s:%d", got.File, got.Line)
+	}
+}
+
+// atWrapper - traces on behalf of its caller as an http.RoundTripper would.
+func atWrapper(ctx context.Context) {
+	at, _ := fn.LvlFrameOutside(fn.Lpar)
+	ctx, end := fn.LogTraceAtCtx(ctx, at, "beg", "k", 1)
+	fn.LogMsgAtCtx(ctx, at, "phase")
+	end("end", "k", 2)
+}
+
+func atCaller(ctx context.Context) { atWrapper(ctx) }
+
+func TestLogTraceAtCtx(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	fn.LogSetOutput(ioutil.Discard)
+	fn.LogSetFlags(log.Lshortfile) // begin reference check uses attributed file
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Tropentraces | fn.Trfuncstats)
+	fn.FuncStatsReset()
+	defer fn.FuncStatsReset()
+
+	rec := fntest.CaptureAll(t)
+
+	atCaller(context.Background())
+	evs := rec.Take()
+	const caller = "github.com/phcurtis/" + pkgName + ".atCaller"
+	if len(evs) != 3 {
+		t.Fatalf("events got:%d want:3 %+v", len(evs), evs)
+	}
+	for i, want := range []struct {
+		kind     int
+		msg      string
+		parentID uint64
+	}{{fn.TraceBeg, "beg", 0}, {fn.TraceMsg, "phase", evs[0].SpanID}, {fn.TraceEnd, "end", 0}} {
+		ev := evs[i]
+		if ev.Kind != want.kind || ev.Func != caller || ev.Msg != want.msg || ev.ParentID != want.parentID ||
+			ev.File != evs[0].File || ev.Line != evs[0].Line {
+			t.Errorf("event %d unexpected:%+v", i, ev)
+		}
+	}
+	if evs[2].SpanID != evs[0].SpanID {
+		t.Errorf("end SpanID got:%d want:%d", evs[2].SpanID, evs[0].SpanID)
+	}
+	if stats := fn.FuncStatsAll(); len(stats) != 1 || stats[0].Func != caller {
+		t.Errorf("func stats got:%+v want attributed to %s", stats, caller)
+	}
+	if n := len(fn.OpenTraces()); n != 0 {
+		t.Errorf("open traces got:%d want:0", n)
+	}
+
+	// zero frame attributes to the invoking func
+	_, end := fn.LogTraceAtCtx(context.Background(), fn.Frame{}, "")
+	end("")
+	evs = rec.Take()
+	if len(evs) != 2 || evs[0].Func != "github.com/phcurtis/"+pkgName+".TestLogTraceAtCtx" {
+		t.Errorf("zero frame events unexpected:%+v", evs)
+	}
+}
@@ -5,10 +5,14 @@
 package fn
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"regexp"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Shortcut flags defining which text to prefix to each log entry generated by the Logger.
@@ -20,7 +24,7 @@ const (
 	LflagsDef   = LflagsCmn                                 // default log.logger log flags
 )
 
-// Trace message labels used during LogTraceZZZ where ZZZ is blank/Msgs/Msgp"
+// Trace message labels used during LogTraceZZZ where ZZZ is blank/Msgs/Msgp/Attrs"
 const (
 	LbegTraceLab     = "BegTrace:"
 	LendTraceLab     = "EndTrace:"
@@ -28,6 +32,8 @@ const (
 	LendTraceMsgsLab = "EndTrMsg:"
 	LbegTraceMsgpLab = "BegTrMsp:"
 	LendTraceMsgpLab = "EndTrMsp:"
+	LbegTraceAttrLab = "BegTrAtr:"
+	LendTraceAttrLab = "EndTrAtr:"
 	LmsgLab          = "Msg:"
 )
 
@@ -62,6 +68,7 @@ func init() {
 	logt = log.New(logOutputDef, LogPrefixDef, LflagsDef)
 	LogSetAlignFile(LogAlignFileDef)
 	LogSetAlignFunc(LogAlignFuncDef)
+	LogSetClock(nil)
 }
 
 // LogSetAlignFile - return alignment [minimum width] for filename stuff
@@ -111,7 +118,7 @@ func LogGetOutputDef() io.Writer {
 
 // lower level with no mutex
 func logSetOutput(iowr io.Writer) {
-	logt.SetOutput(iowr)
+	logt.SetOutput(logOutputTee(iowr))
 	logOutputCur = iowr
 }
 
@@ -169,6 +176,12 @@ const (
 	Trfilenogps                  // when log.Llongfile active filename less gopath src portion
 	Trfnobegref                  // do not print beg reference on EndTrZZZ
 	Trfbegrefincfile             // include filename on beg reference on EndTrZZZ
+	Trallocs                     // print heap allocs and bytes deltas on EndTrZZZ
+	Trgoroutines                 // print number of goroutines delta on EndTrZZZ
+	Trpproflabels                // apply pprof labels fn=funcname,span=id during traced func begun with a ctx or nested in one see LogTraceCtx
+	Trruntrace                   // open runtime/trace region (and task with ctx) during traced func
+	Tropentraces                 // track begun but not ended traced funcs see OpenTraces
+	Trfuncstats                  // aggregate per func call count and durations see FuncStatsAll
 	Trbegtimemicro   = Trbegtime | Trmicroseconds
 	Trendtimemicro   = Trendtime | Trmicroseconds
 	Trmicroboth      = Trbegtime | Trendtime | Trmicroseconds
@@ -191,3 +204,81 @@ func LogTraceFlags() int {
 	defer muLogt.Unlock()
 	return logTraceFlags
 }
+
+var logTraceFilter *regexp.Regexp // nil traces all funcs
+
+// LogSetTraceFilter - sets the trace filter, a regular expression a func
+// full name (such as github.com/phcurtis/fn_test.Foo) must match to be
+// log traced, "" traces all funcs. On error the filter is unchanged.
+func LogSetTraceFilter(expr string) error {
+	re, err := compileTraceFilter(expr)
+	if err != nil {
+		return err
+	}
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	logTraceFilter = re
+	return nil
+}
+
+// LogTraceFilter - return current trace filter, "" if none.
+func LogTraceFilter() string {
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	return traceFilterStr()
+}
+
+func compileTraceFilter(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("fn: trace filter: %v", err)
+	}
+	return re, nil
+}
+
+// lower level with no mutex
+func traceFilterStr() string {
+	if logTraceFilter == nil {
+		return ""
+	}
+	return logTraceFilter.String()
+}
+
+// Clock - source of the current time used by log tracing for
+// begin and end times and durations.
+type Clock interface {
+	Now() time.Time
+}
+
+// sysClock - Clock using time.Now.
+type sysClock struct{}
+
+func (sysClock) Now() time.Time { return time.Now() }
+
+// clockHolder - keeps atomic.Value stored type consistent.
+type clockHolder struct{ c Clock }
+
+var logClock atomic.Value // clockHolder, read without muLogt on each trace
+
+// LogSetClock - sets the Clock used by log tracing; nil restores the
+// system clock. Note the log.logger date/time (LflagsBasic etc.) always
+// uses the system clock, use Trbegtime/Trendtime for reproducible times.
+func LogSetClock(c Clock) {
+	if c == nil {
+		c = sysClock{}
+	}
+	logClock.Store(clockHolder{c})
+}
+
+// LogClock - returns the Clock used by log tracing.
+func LogClock() Clock {
+	return logClock.Load().(clockHolder).c
+}
+
+// clockNow - current time according to the log tracing Clock.
+func clockNow() time.Time {
+	return logClock.Load().(clockHolder).c.Now()
+}
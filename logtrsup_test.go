@@ -5,8 +5,10 @@
 package fn_test
 
 import (
+	"bytes"
 	"io"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/phcurtis/fn"
@@ -70,3 +72,36 @@ func Test_logpairedfuncsgroup(t *testing.T) {
 		})
 	}
 }
+
+func filterA() { defer fn.LogTrace()() }
+func filterB() { defer fn.LogTraceMsgs("b")("b") }
+
+func TestLogSetTraceFilter(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	var buf bytes.Buffer
+	fn.LogSetOutput(&buf)
+	fn.LogSetFlags(0)
+
+	if err := fn.LogSetTraceFilter(`\.filterA$`); err != nil {
+		t.Fatal(err)
+	}
+	if err := fn.LogSetTraceFilter("("); err == nil || fn.LogTraceFilter() != `\.filterA$` {
+		t.Errorf("invalid filter err:%v filter:%q", err, fn.LogTraceFilter())
+	}
+	filterA()
+	filterB()
+	if s := buf.String(); strings.Count(s, "filterA") != 2 || strings.Contains(s, "filterB") {
+		t.Errorf("filtered output unexpected:\n%s", s)
+	}
+
+	buf.Reset()
+	fn.SetPkgCfgDef(false)
+	if fn.LogTraceFilter() != "" {
+		t.Error("SetPkgCfgDef should reset trace filter")
+	}
+	filterB()
+	if s := buf.String(); strings.Count(s, "filterB") != 2 {
+		t.Errorf("unfiltered output unexpected:\n%s", s)
+	}
+}
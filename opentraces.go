@@ -0,0 +1,177 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LopenTraceLab - label of the log lines of LogOpenTraces and LogWatchOpenTraces.
+const LopenTraceLab = "OpnTrace:"
+
+var openSpans = map[uint64]*trSpan{} // span id: begun and not ended span, protected by muLogt
+
+// openTrack - records begun span as open; caller holds muLogt.
+func openTrack(sp *trSpan) {
+	if sp.goid == 0 {
+		sp.goid = Goid()
+	}
+	openSpans[sp.id] = sp
+	sp.tracked = true
+}
+
+// openUntrack - removes span recorded by openTrack.
+func openUntrack(sp *trSpan) {
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	delete(openSpans, sp.id)
+}
+
+// OpenTrace - a traced func begun and not yet ended, see OpenTraces.
+type OpenTrace struct {
+	SpanID   uint64
+	ParentID uint64
+	Func     string // full func name
+	File     string // begin filename
+	Line     int    // begin line num
+	Goid     int64  // goroutine that begun it
+	Begin    time.Time
+	Age      time.Duration // time open as of the OpenTraces call
+}
+
+// String - returns open trace as one line such as
+// "fn_test.worker() age:1m30s beg:worker.go:12 gor:17 span:5".
+func (ot OpenTrace) String() string {
+	return fmt.Sprintf("%s() age:%v beg:%s:%d gor:%d span:%d",
+		filepath.Base(ot.Func), ot.Age, filepath.Base(ot.File), ot.Line, ot.Goid, ot.SpanID)
+}
+
+// OpenTraces - returns the traced funcs begun while Tropentraces was active
+// whose pairing end func has not yet been called, oldest first.
+// Useful for diagnosing hangs, a goroutine blocked forever, or a forgotten
+// pairing end func call; note spans of goroutines ended by os.Exit or
+// runtime.Goexit without running their deferred end funcs remain open.
+func OpenTraces() []OpenTrace {
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	return openTraces(0)
+}
+
+// openTraces - returns open traces older than minAge; caller holds muLogt.
+func openTraces(minAge time.Duration) []OpenTrace {
+	now := clockNow()
+	res := make([]OpenTrace, 0, len(openSpans))
+	for _, sp := range openSpans {
+		age := now.Sub(sp.begTime)
+		if age < minAge {
+			continue
+		}
+		file, line := sp.begPos()
+		res = append(res, OpenTrace{
+			SpanID:   sp.id,
+			ParentID: sp.parentID,
+			Func:     sp.begFn,
+			File:     file,
+			Line:     line,
+			Goid:     sp.goid,
+			Begin:    sp.begTime,
+			Age:      age,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].Begin.Equal(res[j].Begin) {
+			return res[i].Begin.Before(res[j].Begin)
+		}
+		return res[i].SpanID < res[j].SpanID
+	})
+	return res
+}
+
+// logOpenTrace - logs one open trace line; caller holds muLogt.
+func logOpenTrace(ot OpenTrace) {
+	name := ot.Func
+	if logTraceFlags&Trfnbase > 0 {
+		name = filepath.Base(name)
+	}
+	file := ot.File
+	if logTraceFlags&Trfilenogps > 0 && strings.HasPrefix(file, gopathsrc) {
+		file = file[len(gopathsrc):]
+	}
+	logt.Printf("%s %s() age:%v beg:%s:%d gor:%d span:%d",
+		LopenTraceLab, name, ot.Age, file, ot.Line, ot.Goid, ot.SpanID)
+}
+
+// LogOpenTraces - logs a line for each open trace (see OpenTraces) open
+// longer than threshold and returns their number.
+func LogOpenTraces(threshold time.Duration) int {
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	if logTraceFlags&Trlogignore > 0 {
+		return 0
+	}
+	ots := openTraces(threshold)
+	for _, ot := range ots {
+		logOpenTrace(ot)
+	}
+	return len(ots)
+}
+
+// OpenTracesWatchIntervalDef - default checking interval of
+// LogWatchOpenTraces when its threshold is under 2ns.
+const OpenTracesWatchIntervalDef = 10 * time.Second
+
+// LogWatchOpenTraces - starts a watchdog checking every interval (half the
+// threshold if < 1, see OpenTracesWatchIntervalDef) for open traces
+// (see OpenTraces) open longer than threshold and logging a line for
+// each once, and returns a func stopping it. Requires Tropentraces.
+//
+//	Example: defer fn.LogWatchOpenTraces(time.Minute, 10*time.Second)()
+func LogWatchOpenTraces(threshold, interval time.Duration) (stop func()) {
+	if interval < 1 {
+		interval = threshold / 2
+	}
+	if interval < 1 {
+		interval = OpenTracesWatchIntervalDef
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		reported := make(map[uint64]bool)
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+			}
+			muLogt.Lock()
+			ots := openTraces(threshold)
+			open := make(map[uint64]bool, len(ots))
+			for _, ot := range ots {
+				open[ot.SpanID] = true
+				if !reported[ot.SpanID] && logTraceFlags&Trlogignore == 0 {
+					logOpenTrace(ot)
+				}
+			}
+			muLogt.Unlock()
+			reported = open
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phcurtis/fn"
+	"github.com/phcurtis/fn/fntest"
+)
+
+// lockedBuf - bytes.Buffer safe for a concurrent writer and reader.
+type lockedBuf struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuf) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuf) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func openBlocker(started, release chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer fn.LogTrace()()
+	started <- struct{}{}
+	<-release
+}
+
+func TestOpenTraces(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	var buf lockedBuf
+	fn.LogSetOutput(&buf)
+	fn.LogSetFlags(0)
+	fn.LogSetPrefix("")
+	clk := fntest.NewClock(time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC), 0)
+	fn.LogSetClock(clk)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go openBlocker(started, release, &wg) // not tracked
+	<-started
+	if n := len(fn.OpenTraces()); n != 0 {
+		t.Errorf("without Tropentraces open traces got:%d want:0", n)
+	}
+
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Tropentraces)
+	wg.Add(2)
+	go openBlocker(started, release, &wg)
+	<-started
+	clk.Advance(time.Minute)
+	go openBlocker(started, release, &wg)
+	<-started
+	clk.Advance(30 * time.Second)
+
+	ots := fn.OpenTraces()
+	if len(ots) != 2 {
+		t.Fatalf("open traces got:%d want:2", len(ots))
+	}
+	wantFn := "github.com/phcurtis/" + pkgName + ".openBlocker"
+	for i, wantAge := range []time.Duration{90 * time.Second, 30 * time.Second} {
+		ot := ots[i]
+		if ot.Age != wantAge || ot.Func != wantFn || !strings.HasSuffix(ot.File, "opentraces_test.go") ||
+			ot.Line == 0 || ot.Goid == fn.Goid() || ot.Goid == 0 || ot.SpanID == 0 {
+			t.Errorf("ots[%d] unexpected:%+v", i, ot)
+		}
+	}
+	if !strings.HasPrefix(ots[0].String(), pkgName+".openBlocker() age:1m30s beg:opentraces_test.go:") {
+		t.Errorf("String unexpected:%s", ots[0])
+	}
+
+	buf.mu.Lock()
+	buf.buf.Reset()
+	buf.mu.Unlock()
+	if n := fn.LogOpenTraces(time.Minute); n != 1 {
+		t.Errorf("LogOpenTraces got:%d want:1", n)
+	}
+	want := fn.LopenTraceLab + " " + pkgName + ".openBlocker() age:1m30s beg:"
+	if s := buf.String(); !strings.HasPrefix(s, want) || strings.Count(s, "\n") != 1 {
+		t.Errorf("LogOpenTraces output got:%q want prefix:%q", s, want)
+	}
+
+	close(release)
+	wg.Wait()
+	if n := len(fn.OpenTraces()); n != 0 {
+		t.Errorf("after end open traces got:%d want:0", n)
+	}
+}
+
+func TestLogWatchOpenTraces(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	var buf lockedBuf
+	fn.LogSetOutput(&buf)
+	fn.LogSetFlags(0)
+	fn.LogSetPrefix("")
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Tropentraces)
+	clk := fntest.NewClock(time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC), 0)
+	fn.LogSetClock(clk)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go openBlocker(started, release, &wg)
+	<-started
+	defer func() {
+		close(release)
+		wg.Wait()
+	}()
+
+	stop := fn.LogWatchOpenTraces(time.Minute, time.Millisecond)
+	defer stop()
+	time.Sleep(5 * time.Millisecond)
+	if strings.Contains(buf.String(), fn.LopenTraceLab) {
+		t.Fatalf("logged span younger than threshold:\n%s", buf.String())
+	}
+	clk.Advance(2 * time.Minute)
+	for i := 0; i < 1000 && !strings.Contains(buf.String(), fn.LopenTraceLab); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+	stop()
+	if n := strings.Count(buf.String(), fn.LopenTraceLab+" "+pkgName+".openBlocker() age:2m0s"); n != 1 {
+		t.Errorf("watchdog lines got:%d want:1 output:\n%s", n, buf.String())
+	}
+}
+
+func TestLogWatchOpenTracesIntervalDef(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	var buf lockedBuf
+	fn.LogSetOutput(&buf)
+	fn.LogSetFlags(0)
+	fn.LogSetPrefix("")
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Tropentraces)
+	clk := fntest.NewClock(time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC), 0)
+	fn.LogSetClock(clk)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go openBlocker(started, release, &wg)
+	<-started
+	defer func() {
+		close(release)
+		wg.Wait()
+	}()
+
+	fn.LogWatchOpenTraces(0, 0)() // no threshold either, must not panic
+	stop := fn.LogWatchOpenTraces(2*time.Millisecond, 0)
+	defer stop()
+	clk.Advance(time.Minute)
+	waitFor(t, "open trace line", func() bool { return strings.Contains(buf.String(), fn.LopenTraceLab) })
+}
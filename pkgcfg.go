@@ -5,7 +5,11 @@
 package fn
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"log"
+	"strings"
 )
 
 // PkgCfgStruct - package config structure less log Output.
@@ -15,6 +19,8 @@ type PkgCfgStruct struct {
 	LogTraceFlags int
 	LogAlignFile  int
 	LogAlignFunc  int
+
+	LogTraceFilter string // see LogSetTraceFilter, if invalid SetPkgCfg logs the error and leaves filter unchanged
 }
 
 // PkgCfgDef - returns package config defaults and logOutput
@@ -31,7 +37,8 @@ func PkgCfgDef() (pkgCfg *PkgCfgStruct, logOutput io.Writer) {
 	return &p, logOutputDef
 }
 
-// SetPkgCfgDef - sets this package configuration to its defaults.
+// SetPkgCfgDef - sets this package configuration to its defaults
+// including the system Clock.
 func SetPkgCfgDef(resetLogOutput bool) {
 	muLogt.Lock()
 	defer muLogt.Unlock()
@@ -41,6 +48,8 @@ func SetPkgCfgDef(resetLogOutput bool) {
 	logTraceFlags = TrFlagsDef
 	logAlignFile = LogAlignFileDef
 	logAlignFunc = LogAlignFuncDef
+	logTraceFilter = nil
+	LogSetClock(nil)
 	if resetLogOutput {
 		logSetOutput(logOutputDef)
 	}
@@ -51,29 +60,200 @@ func SetPkgCfgDef(resetLogOutput bool) {
 func PkgCfg() (pkgCfg *PkgCfgStruct, logOutput io.Writer) {
 	muLogt.Lock()
 	defer muLogt.Unlock()
-	p := PkgCfgStruct{
-		LogFlags:      logt.Flags(),
-		LogPrefix:     logt.Prefix(),
-		LogTraceFlags: logTraceFlags,
-		LogAlignFile:  logAlignFile,
-		LogAlignFunc:  logAlignFunc,
-	}
+	p := curPkgCfg()
 	return &p, logOutputCur
 }
 
+// lower level with no mutex
+func curPkgCfg() PkgCfgStruct {
+	return PkgCfgStruct{
+		LogFlags:       logt.Flags(),
+		LogPrefix:      logt.Prefix(),
+		LogTraceFlags:  logTraceFlags,
+		LogAlignFile:   logAlignFile,
+		LogAlignFunc:   logAlignFunc,
+		LogTraceFilter: traceFilterStr(),
+	}
+}
+
 // SetPkgCfg - updates the passed in PkgCfgStruct to applicable vars
-// and logOutput if that is not nil.
+// and logOutput if that is not nil. A nil p leaves the config unchanged.
+// An invalid LogTraceFilter is reported on the log output and the filter
+// left unchanged; see SetPkgCfgChecked to apply nothing when invalid.
 func SetPkgCfg(p *PkgCfgStruct, logOutput io.Writer) {
 	muLogt.Lock()
 	defer muLogt.Unlock()
+	setPkgCfg(p, logOutput)
+}
 
-	logt.SetFlags(p.LogFlags)
-	logt.SetPrefix(p.LogPrefix)
-	logTraceFlags = p.LogTraceFlags
-	logAlignFile = p.LogAlignFile
-	logAlignFunc = p.LogAlignFunc
+// lower level with no mutex
+func setPkgCfg(p *PkgCfgStruct, logOutput io.Writer) {
+	if p != nil {
+		logt.SetFlags(p.LogFlags)
+		logt.SetPrefix(p.LogPrefix)
+		logTraceFlags = p.LogTraceFlags
+		logAlignFile = p.LogAlignFile
+		logAlignFunc = p.LogAlignFunc
+		if p.LogTraceFilter != traceFilterStr() {
+			re, err := compileTraceFilter(p.LogTraceFilter)
+			if err != nil {
+				logt.Printf("SetPkgCfg: %v, trace filter left unchanged", err)
+			} else {
+				logTraceFilter = re
+			}
+		}
+	}
 	if logOutput != nil {
 		logSetOutput(logOutput)
 	}
-	return
+}
+
+// pushedCfg - a package config saved by PushPkgCfg.
+type pushedCfg struct {
+	cfg    PkgCfgStruct
+	output io.Writer
+	where  string // file:line:func of the PushPkgCfg invoker
+}
+
+var pkgCfgStack []*pushedCfg // protected by muLogt
+
+// PushPkgCfg - saves the current package config and log output on a stack,
+// applies the config as modified by mod (if not nil) and returns a func
+// restoring the saved config and log output. Pushes may be nested however
+// each restore must be called in reverse order of its push, an out of order
+// restore panics; calling a restore more than once does nothing.
+//
+//	Idiomatic usage:
+//	defer fn.PushPkgCfg(func(c *fn.PkgCfgStruct) { c.LogTraceFlags |= fn.Trbegtime })()
+func PushPkgCfg(mod func(c *PkgCfgStruct)) (restore func()) {
+	where := LvlInfoCmn(Lpar)
+	muLogt.Lock()
+	e := &pushedCfg{cfg: curPkgCfg(), output: logOutputCur, where: where}
+	pkgCfgStack = append(pkgCfgStack, e)
+	muLogt.Unlock()
+
+	if mod != nil {
+		c := e.cfg
+		mod(&c) // outside of muLogt so mod may invoke this package
+		SetPkgCfg(&c, nil)
+	}
+
+	var done bool
+	return func() {
+		muLogt.Lock()
+		defer muLogt.Unlock()
+		if done {
+			return
+		}
+		n := len(pkgCfgStack)
+		if n == 0 || pkgCfgStack[n-1] != e {
+			top := "none"
+			if n > 0 {
+				top = pkgCfgStack[n-1].where
+			}
+			logt.Panic(errors.New("fn: PushPkgCfg restore out of order\n pushed at:" +
+				e.where + "\n top of stack pushed at:" + top))
+		}
+		done = true
+		pkgCfgStack[n-1] = nil
+		pkgCfgStack = pkgCfgStack[:n-1]
+		setPkgCfg(&e.cfg, e.output)
+	}
+}
+
+// PkgCfgStackLen - returns the number of package configs saved by
+// PushPkgCfg not yet restored.
+func PkgCfgStackLen() int {
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	return len(pkgCfgStack)
+}
+
+// ErrPkgCfgNil - error of a nil *PkgCfgStruct.
+var ErrPkgCfgNil = errors.New("fn: nil PkgCfgStruct")
+
+// PkgCfgErrors - the problems of a PkgCfgStruct found by Validate.
+type PkgCfgErrors []error
+
+func (e PkgCfgErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return fmt.Sprintf("fn: invalid PkgCfgStruct (%d problems): %s", len(e), strings.Join(s, "; "))
+}
+
+// contradictory flags of PkgCfgStruct, any both of a and b set.
+var pkgCfgContra = []struct {
+	trace bool // LogTraceFlags else LogFlags
+	a, b  int
+	what  string
+}{
+	{false, log.Lshortfile, log.Llongfile, "log flags Lshortfile and Llongfile"},
+	{true, Trfnobegref, Trfbegrefincfile, "trace flags Trfnobegref and Trfbegrefincfile"},
+	{true, Trnodur, Trallocs | Trgoroutines | Trfuncstats, "trace flags Trnodur and stats Trallocs|Trgoroutines|Trfuncstats"},
+}
+
+// Validate - returns nil if config is valid else ErrPkgCfgNil or PkgCfgErrors
+// listing all its problems: align widths out of range, an invalid trace filter,
+// unknown flags and contradictory flags such as Trfnobegref with Trfbegrefincfile.
+func (p *PkgCfgStruct) Validate() error {
+	if p == nil {
+		return ErrPkgCfgNil
+	}
+	var errs PkgCfgErrors
+	if p.LogAlignFile < 0 || p.LogAlignFile > LogAlignFileMax {
+		errs = append(errs, fmt.Errorf("LogAlignFile %d not within 0..%d", p.LogAlignFile, LogAlignFileMax))
+	}
+	if p.LogAlignFunc < 0 || p.LogAlignFunc > LogAlignFuncMax {
+		errs = append(errs, fmt.Errorf("LogAlignFunc %d not within 0..%d", p.LogAlignFunc, LogAlignFuncMax))
+	}
+	if _, err := compileTraceFilter(p.LogTraceFilter); err != nil {
+		errs = append(errs, errors.New(strings.TrimPrefix(err.Error(), "fn: ")))
+	}
+	if u := p.LogFlags &^ flagsMask(logFlagNames); u != 0 {
+		errs = append(errs, fmt.Errorf("unknown log flags %#x", u))
+	}
+	if u := p.LogTraceFlags &^ flagsMask(traceFlagNames); u != 0 {
+		errs = append(errs, fmt.Errorf("unknown trace flags %#x", u))
+	}
+	for _, c := range pkgCfgContra {
+		flags := p.LogFlags
+		if c.trace {
+			flags = p.LogTraceFlags
+		}
+		if flags&c.a != 0 && flags&c.b != 0 {
+			errs = append(errs, fmt.Errorf("%s are contradictory", c.what))
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// SetPkgCfgChecked - same as SetPkgCfg however first clamps the align widths
+// to their valid range (as LogSetAlignFile and LogSetAlignFunc do) and then
+// applies nothing returning the Validate error if config is still invalid.
+func SetPkgCfgChecked(p *PkgCfgStruct, logOutput io.Writer) error {
+	if p == nil {
+		return ErrPkgCfgNil
+	}
+	q := *p
+	q.LogAlignFile = clampAlign(q.LogAlignFile, LogAlignFileMax)
+	q.LogAlignFunc = clampAlign(q.LogAlignFunc, LogAlignFuncMax)
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	SetPkgCfg(&q, logOutput)
+	return nil
+}
+
+func clampAlign(width, max int) int {
+	if width > max {
+		return max
+	} else if width < 0 {
+		return 0
+	}
+	return width
 }
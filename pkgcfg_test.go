@@ -7,7 +7,9 @@ package fn_test
 import (
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/phcurtis/fn"
@@ -132,3 +134,182 @@ func Test_pkgcfg_matches_setpkgcfg(t *testing.T) {
 
 	fn.SetPkgCfgDef(true) // set pkg config to (what should be) a known default state
 }
+
+func TestPkgCfgValidate(t *testing.T) {
+	var nilCfg *fn.PkgCfgStruct
+	if err := nilCfg.Validate(); err != fn.ErrPkgCfgNil {
+		t.Errorf("nil Validate got:%v want:%v", err, fn.ErrPkgCfgNil)
+	}
+
+	tests := []struct {
+		name string
+		mod  func(p *fn.PkgCfgStruct)
+		errs []string // substrings of each problem, nil if valid
+	}{
+		{"defaults", func(p *fn.PkgCfgStruct) {}, nil},
+		{"alignfile", func(p *fn.PkgCfgStruct) { p.LogAlignFile = fn.LogAlignFileMax + 1 },
+			[]string{"LogAlignFile 51 not within 0..50"}},
+		{"alignfunc", func(p *fn.PkgCfgStruct) { p.LogAlignFunc = -1 },
+			[]string{"LogAlignFunc -1 not within 0..50"}},
+		{"logfiles", func(p *fn.PkgCfgStruct) { p.LogFlags |= log.Llongfile },
+			[]string{"Lshortfile and Llongfile"}},
+		{"begref", func(p *fn.PkgCfgStruct) { p.LogTraceFlags |= fn.Trfnobegref | fn.Trfbegrefincfile },
+			[]string{"Trfnobegref and Trfbegrefincfile"}},
+		{"nodurallocs", func(p *fn.PkgCfgStruct) { p.LogTraceFlags |= fn.Trnodur | fn.Trallocs },
+			[]string{"Trnodur and stats"}},
+		{"nodurgor", func(p *fn.PkgCfgStruct) { p.LogTraceFlags |= fn.Trnodur | fn.Trgoroutines },
+			[]string{"Trnodur and stats"}},
+		{"nodurstats", func(p *fn.PkgCfgStruct) { p.LogTraceFlags |= fn.Trnodur | fn.Trfuncstats },
+			[]string{"Trnodur and stats"}},
+		{"nodur", func(p *fn.PkgCfgStruct) { p.LogTraceFlags = fn.TrFlagsOff }, nil},
+		{"unknown", func(p *fn.PkgCfgStruct) { p.LogFlags |= 1 << 30; p.LogTraceFlags |= 1 << 30 },
+			[]string{"unknown log flags 0x40000000", "unknown trace flags 0x40000000"}},
+		{"multi", func(p *fn.PkgCfgStruct) {
+			p.LogAlignFile = 99
+			p.LogFlags |= log.Llongfile
+			p.LogTraceFlags |= fn.Trnodur | fn.Trallocs
+		}, []string{"LogAlignFile 99", "Lshortfile and Llongfile", "Trnodur"}},
+	}
+	for _, tc := range tests {
+		p, _ := fn.PkgCfgDef()
+		tc.mod(p)
+		err := p.Validate()
+		if tc.errs == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected err:%v", tc.name, err)
+			}
+			continue
+		}
+		errs, ok := err.(fn.PkgCfgErrors)
+		if !ok || len(errs) != len(tc.errs) {
+			t.Errorf("%s: err got:%v want %d problems", tc.name, err, len(tc.errs))
+			continue
+		}
+		for i, sub := range tc.errs {
+			if !strings.Contains(errs[i].Error(), sub) {
+				t.Errorf("%s: problem %d got:%q want containing:%q", tc.name, i, errs[i], sub)
+			}
+		}
+		if want := fmt.Sprintf("(%d problems)", len(errs)); !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: Error() got:%q want containing:%q", tc.name, err, want)
+		}
+	}
+}
+
+func TestSetPkgCfgChecked(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	if err := fn.SetPkgCfgChecked(nil, nil); err != fn.ErrPkgCfgNil {
+		t.Errorf("nil got:%v want:%v", err, fn.ErrPkgCfgNil)
+	}
+
+	// align widths are clamped
+	p, _ := fn.PkgCfgDef()
+	p.LogAlignFile, p.LogAlignFunc = 99, -3
+	if err := fn.SetPkgCfgChecked(p, nil); err != nil {
+		t.Fatalf("unexpected err:%v", err)
+	}
+	got, _ := fn.PkgCfg()
+	if got.LogAlignFile != fn.LogAlignFileMax || got.LogAlignFunc != 0 {
+		t.Errorf("align not clamped got:%+v", got)
+	}
+	if p.LogAlignFile != 99 {
+		t.Error("SetPkgCfgChecked should not modify its argument")
+	}
+
+	// invalid config is not applied
+	before, bwr := fn.PkgCfg()
+	p, _ = fn.PkgCfgDef()
+	p.LogPrefix = "bad"
+	p.LogTraceFlags |= fn.Trfnobegref | fn.Trfbegrefincfile
+	if err := fn.SetPkgCfgChecked(p, os.Stderr); err == nil {
+		t.Error("contradictory flags should error")
+	}
+	after, awr := fn.PkgCfg()
+	if *after != *before || awr != bwr {
+		t.Errorf("invalid config applied got:%+v want:%+v", after, before)
+	}
+}
+
+func TestSetPkgCfgNilAndBadFilter(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	var buf strings.Builder
+	before, _ := fn.PkgCfg()
+	fn.SetPkgCfg(nil, &buf)
+	after, wr := fn.PkgCfg()
+	if *after != *before || wr != &buf {
+		t.Errorf("nil config got:%+v,%v want:%+v,%v", after, wr, before, &buf)
+	}
+
+	p, _ := fn.PkgCfgDef()
+	p.LogTraceFilter = "("
+	fn.SetPkgCfg(p, nil)
+	if got := fn.LogTraceFilter(); got != "" {
+		t.Errorf("filter got:%q want unchanged", got)
+	}
+	if got := buf.String(); !strings.Contains(got, "SetPkgCfg: fn: trace filter:") {
+		t.Errorf("invalid filter not reported got:%q", got)
+	}
+}
+
+func TestPushPkgCfg(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	def, defwr := fn.PkgCfg()
+
+	var buf strings.Builder
+	restore1 := fn.PushPkgCfg(func(c *fn.PkgCfgStruct) {
+		c.LogTraceFlags |= fn.Trbegtime
+		c.LogPrefix = "one"
+	})
+	fn.LogSetOutput(&buf)
+	restore2 := fn.PushPkgCfg(func(c *fn.PkgCfgStruct) {
+		if c.LogPrefix != "one" {
+			t.Errorf("nested push got prefix:%q want:%q", c.LogPrefix, "one")
+		}
+		c.LogPrefix = "two"
+		c.LogAlignFile = 3
+	})
+	if got, _ := fn.PkgCfg(); got.LogPrefix != "two" || got.LogAlignFile != 3 ||
+		got.LogTraceFlags != fn.TrFlagsDef|fn.Trbegtime {
+		t.Errorf("after 2 pushes got:%+v", got)
+	}
+	if n := fn.PkgCfgStackLen(); n != 2 {
+		t.Errorf("PkgCfgStackLen got:%d want:2", n)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("out of order restore should panic")
+			}
+		}()
+		restore1()
+	}()
+	if !strings.Contains(buf.String(), "restore out of order") ||
+		!strings.Contains(buf.String(), "pkgcfg_test.go:") {
+		t.Errorf("out of order restore output:%q", buf.String())
+	}
+
+	restore2()
+	restore2() // no-op
+	if got, gotwr := fn.PkgCfg(); got.LogPrefix != "one" || got.LogAlignFile != def.LogAlignFile ||
+		gotwr != io.Writer(&buf) {
+		t.Errorf("after restore2 got:%+v", got)
+	}
+	restore1()
+	if got, gotwr := fn.PkgCfg(); *got != *def || gotwr != defwr {
+		t.Errorf("after restore1 got:%+v want:%+v", got, def)
+	}
+	if n := fn.PkgCfgStackLen(); n != 0 {
+		t.Errorf("PkgCfgStackLen got:%d want:0", n)
+	}
+
+	restore := fn.PushPkgCfg(nil)
+	fn.LogSetPrefix("changed")
+	restore()
+	if p := fn.LogPrefix(); p != def.LogPrefix {
+		t.Errorf("nil mod restore got prefix:%q want:%q", p, def.LogPrefix)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// flagName - symbolic name of a single bit flag.
+type flagName struct {
+	name string
+	bit  int
+}
+
+// logFlagNames - names of the stdlib log flags, the log.L prefix lower cased.
+var logFlagNames = []flagName{
+	{"date", log.Ldate},
+	{"time", log.Ltime},
+	{"microseconds", log.Lmicroseconds},
+	{"longfile", log.Llongfile},
+	{"shortfile", log.Lshortfile},
+	{"utc", log.LUTC},
+	{"msgprefix", log.Lmsgprefix},
+}
+
+// traceFlagNames - names of the Trace Flags, the Tr prefix lower cased.
+var traceFlagNames = []flagName{
+	{"logignore", Trlogignore},
+	{"begtime", Trbegtime},
+	{"endtime", Trendtime},
+	{"microseconds", Trmicroseconds},
+	{"nodur", Trnodur},
+	{"fnbase", Trfnbase},
+	{"filenogps", Trfilenogps},
+	{"fnobegref", Trfnobegref},
+	{"fbegrefincfile", Trfbegrefincfile},
+	{"allocs", Trallocs},
+	{"goroutines", Trgoroutines},
+	{"pproflabels", Trpproflabels},
+	{"runtrace", Trruntrace},
+	{"opentraces", Tropentraces},
+	{"funcstats", Trfuncstats},
+}
+
+// flagsNames - returns the sorted names of the bits set in flags and
+// any remaining unnamed bits as one hex number.
+func flagsNames(flags int, names []flagName) []string {
+	res := []string{}
+	for _, fn := range names {
+		if flags&fn.bit != 0 {
+			res = append(res, fn.name)
+			flags &^= fn.bit
+		}
+	}
+	sort.Strings(res)
+	if flags != 0 {
+		res = append(res, fmt.Sprintf("%#x", flags))
+	}
+	return res
+}
+
+// flagsMask - returns all the bits of names.
+func flagsMask(names []flagName) int {
+	var mask int
+	for _, fn := range names {
+		mask |= fn.bit
+	}
+	return mask
+}
+
+// parseFlagsNames - returns flags of names, each a name of names with or
+// without the pfix ignoring case, or a number as accepted by strconv.ParseInt.
+func parseFlagsNames(what, pfix string, list []string, names []flagName) (int, error) {
+	var flags int
+next:
+	for _, s := range list {
+		ls := strings.ToLower(s)
+		for _, fn := range names {
+			if ls == fn.name || ls == pfix+fn.name {
+				flags |= fn.bit
+				continue next
+			}
+		}
+		n, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unknown %s flag %q", what, s)
+		}
+		flags |= int(n)
+	}
+	return flags, nil
+}
+
+func splitFlags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == '|' || r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// LogFlagsText - returns stdlib log flags as symbolic names joined by '|'
+// such as "date|shortfile|time", "0" if none.
+func LogFlagsText(flags int) string {
+	if flags == 0 {
+		return "0"
+	}
+	return strings.Join(flagsNames(flags, logFlagNames), "|")
+}
+
+// ParseLogFlags - returns stdlib log flags of s in the form of LogFlagsText,
+// names may also be separated by commas or spaces and include the L prefix.
+func ParseLogFlags(s string) (int, error) {
+	flags, err := parseFlagsNames("log", "l", splitFlags(s), logFlagNames)
+	if err != nil {
+		return 0, fmt.Errorf("fn: %v", err)
+	}
+	return flags, nil
+}
+
+// TraceFlagsText - returns Trace Flags as symbolic names joined by '|'
+// such as "filenogps|fnbase", "0" if none.
+func TraceFlagsText(flags int) string {
+	if flags == 0 {
+		return "0"
+	}
+	return strings.Join(flagsNames(flags, traceFlagNames), "|")
+}
+
+// ParseTraceFlags - returns Trace Flags of s in the form of TraceFlagsText,
+// names may also be separated by commas or spaces and include the Tr prefix.
+func ParseTraceFlags(s string) (int, error) {
+	flags, err := parseFlagsNames("trace", "tr", splitFlags(s), traceFlagNames)
+	if err != nil {
+		return 0, fmt.Errorf("fn: %v", err)
+	}
+	return flags, nil
+}
+
+// pkgCfgJSON - JSON form of PkgCfgStruct.
+type pkgCfgJSON struct {
+	LogFlags      []string `json:"log_flags"`
+	LogPrefix     string   `json:"prefix"`
+	LogTraceFlags []string `json:"trace_flags"`
+	LogAlignFile  int      `json:"align_file"`
+	LogAlignFunc  int      `json:"align_func"`
+	TraceFilter   string   `json:"trace_filter,omitempty"`
+}
+
+// MarshalJSON - implements json.Marshaler with flags as arrays of names
+// such as {"log_flags":["date","shortfile","time"],"prefix":"LogFN: ",
+// "trace_flags":["filenogps","fnbase"],"align_file":16,"align_func":0}.
+func (p PkgCfgStruct) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pkgCfgJSON{
+		LogFlags:      flagsNames(p.LogFlags, logFlagNames),
+		LogPrefix:     p.LogPrefix,
+		LogTraceFlags: flagsNames(p.LogTraceFlags, traceFlagNames),
+		LogAlignFile:  p.LogAlignFile,
+		LogAlignFunc:  p.LogAlignFunc,
+		TraceFilter:   p.LogTraceFilter,
+	})
+}
+
+// UnmarshalJSON - implements json.Unmarshaler for the form of MarshalJSON,
+// fields absent in data are left unchanged.
+func (p *PkgCfgStruct) UnmarshalJSON(data []byte) error {
+	j := pkgCfgJSON{
+		LogPrefix:    p.LogPrefix,
+		LogAlignFile: p.LogAlignFile,
+		LogAlignFunc: p.LogAlignFunc,
+		TraceFilter:  p.LogTraceFilter,
+	}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	q := *p
+	var err error
+	if j.LogFlags != nil {
+		if q.LogFlags, err = parseFlagsNames("log", "l", j.LogFlags, logFlagNames); err != nil {
+			return fmt.Errorf("fn: pkgcfg log_flags: %v", err)
+		}
+	}
+	if j.LogTraceFlags != nil {
+		if q.LogTraceFlags, err = parseFlagsNames("trace", "tr", j.LogTraceFlags, traceFlagNames); err != nil {
+			return fmt.Errorf("fn: pkgcfg trace_flags: %v", err)
+		}
+	}
+	q.LogPrefix = j.LogPrefix
+	q.LogAlignFile = j.LogAlignFile
+	q.LogAlignFunc = j.LogAlignFunc
+	q.LogTraceFilter = j.TraceFilter
+	*p = q
+	return nil
+}
+
+// MarshalText - implements encoding.TextMarshaler in a YAML like form of
+// one "key: value" line per field with flags as in LogFlagsText and
+// TraceFlagsText and strings quoted, trace_filter only if not empty, such as:
+//
+//	log_flags: date|shortfile|time
+//	prefix: "LogFN: "
+//	trace_flags: filenogps|fnbase
+//	align_file: 16
+//	align_func: 0
+//	trace_filter: "^main\\."
+func (p PkgCfgStruct) MarshalText() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "log_flags: %s\n", LogFlagsText(p.LogFlags))
+	fmt.Fprintf(&b, "prefix: %s\n", strconv.Quote(p.LogPrefix))
+	fmt.Fprintf(&b, "trace_flags: %s\n", TraceFlagsText(p.LogTraceFlags))
+	fmt.Fprintf(&b, "align_file: %d\n", p.LogAlignFile)
+	fmt.Fprintf(&b, "align_func: %d\n", p.LogAlignFunc)
+	if p.LogTraceFilter != "" {
+		fmt.Fprintf(&b, "trace_filter: %s\n", strconv.Quote(p.LogTraceFilter))
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalText - implements encoding.TextUnmarshaler for the form of
+// MarshalText, blank lines and lines beginning with '#' are ignored,
+// unquoted strings are taken as is and keys absent in text are left unchanged.
+func (p *PkgCfgStruct) UnmarshalText(text []byte) error {
+	q := *p
+	sc := bufio.NewScanner(bytes.NewReader(text))
+	for lnum := 1; sc.Scan(); lnum++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return fmt.Errorf("fn: pkgcfg line %d: missing ':' in %q", lnum, line)
+		}
+		key, val := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		var err error
+		switch key {
+		case "log_flags":
+			q.LogFlags, err = parseFlagsNames("log", "l", splitFlags(val), logFlagNames)
+		case "prefix":
+			q.LogPrefix, err = unquoteText(val)
+		case "trace_filter":
+			q.LogTraceFilter, err = unquoteText(val)
+		case "trace_flags":
+			q.LogTraceFlags, err = parseFlagsNames("trace", "tr", splitFlags(val), traceFlagNames)
+		case "align_file":
+			q.LogAlignFile, err = strconv.Atoi(val)
+		case "align_func":
+			q.LogAlignFunc, err = strconv.Atoi(val)
+		default:
+			err = fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return fmt.Errorf("fn: pkgcfg line %d: %v", lnum, err)
+		}
+	}
+	*p = q
+	return sc.Err()
+}
+
+func unquoteText(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		return strconv.Unquote(s)
+	}
+	return s, nil
+}
+
+// LoadPkgCfg - returns the package config read from the file at path on top
+// of the package config defaults (see PkgCfgDef), in the form of MarshalJSON
+// if its content begins with '{' else of MarshalText. The config is not applied,
+// use SetPkgCfg for that.
+func LoadPkgCfg(path string) (*PkgCfgStruct, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := parsePkgCfg(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+// parsePkgCfg - returns package config of data on top of the defaults
+// in the form of MarshalJSON if data begins with '{' else of MarshalText.
+func parsePkgCfg(data []byte) (*PkgCfgStruct, error) {
+	p, _ := PkgCfgDef()
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		err = json.Unmarshal(data, p)
+	} else {
+		err = p.UnmarshalText(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phcurtis/fn"
+)
+
+var logFlagsAll = []struct {
+	name string
+	flag int
+}{
+	{"date", log.Ldate},
+	{"time", log.Ltime},
+	{"microseconds", log.Lmicroseconds},
+	{"longfile", log.Llongfile},
+	{"shortfile", log.Lshortfile},
+	{"utc", log.LUTC},
+	{"msgprefix", log.Lmsgprefix},
+}
+
+var traceFlagsAll = []struct {
+	name string
+	flag int
+}{
+	{"logignore", fn.Trlogignore},
+	{"begtime", fn.Trbegtime},
+	{"endtime", fn.Trendtime},
+	{"microseconds", fn.Trmicroseconds},
+	{"nodur", fn.Trnodur},
+	{"fnbase", fn.Trfnbase},
+	{"filenogps", fn.Trfilenogps},
+	{"fnobegref", fn.Trfnobegref},
+	{"fbegrefincfile", fn.Trfbegrefincfile},
+	{"allocs", fn.Trallocs},
+	{"goroutines", fn.Trgoroutines},
+	{"pproflabels", fn.Trpproflabels},
+	{"runtrace", fn.Trruntrace},
+	{"opentraces", fn.Tropentraces},
+	{"funcstats", fn.Trfuncstats},
+}
+
+func pkgCfgRoundTrip(t *testing.T, p *fn.PkgCfgStruct) {
+	t.Helper()
+	text, err := p.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotText fn.PkgCfgStruct
+	if err := gotText.UnmarshalText(text); err != nil {
+		t.Fatalf("UnmarshalText err:%v text:\n%s", err, text)
+	}
+	if gotText != *p {
+		t.Errorf("text round trip\n got:%+v\nwant:%+v\ntext:\n%s", gotText, *p, text)
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotJSON fn.PkgCfgStruct
+	if err := json.Unmarshal(data, &gotJSON); err != nil {
+		t.Fatalf("json.Unmarshal err:%v json:%s", err, data)
+	}
+	if gotJSON != *p {
+		t.Errorf("json round trip\n got:%+v\nwant:%+v\njson:%s", gotJSON, *p, data)
+	}
+}
+
+func TestPkgCfgMarshalFlags(t *testing.T) {
+	var allLog, allTrace int
+	for _, v := range logFlagsAll {
+		allLog |= v.flag
+		if got := fn.LogFlagsText(v.flag); got != v.name {
+			t.Errorf("LogFlagsText(%#x) got:%q want:%q", v.flag, got, v.name)
+		}
+		for _, s := range []string{v.name, "L" + v.name, strings.ToUpper(v.name)} {
+			if got, err := fn.ParseLogFlags(s); got != v.flag || err != nil {
+				t.Errorf("ParseLogFlags(%q) got:%#x,%v want:%#x", s, got, err, v.flag)
+			}
+		}
+		pkgCfgRoundTrip(t, &fn.PkgCfgStruct{LogFlags: v.flag, LogPrefix: "p"})
+	}
+	for _, v := range traceFlagsAll {
+		allTrace |= v.flag
+		if got := fn.TraceFlagsText(v.flag); got != v.name {
+			t.Errorf("TraceFlagsText(%#x) got:%q want:%q", v.flag, got, v.name)
+		}
+		for _, s := range []string{v.name, "Tr" + v.name, strings.ToUpper(v.name)} {
+			if got, err := fn.ParseTraceFlags(s); got != v.flag || err != nil {
+				t.Errorf("ParseTraceFlags(%q) got:%#x,%v want:%#x", s, got, err, v.flag)
+			}
+		}
+		pkgCfgRoundTrip(t, &fn.PkgCfgStruct{LogTraceFlags: v.flag})
+	}
+	pkgCfgRoundTrip(t, &fn.PkgCfgStruct{LogFlags: allLog, LogTraceFlags: allTrace})
+	pkgCfgRoundTrip(t, &fn.PkgCfgStruct{}) // no flags
+	// unnamed bits kept as a number
+	pkgCfgRoundTrip(t, &fn.PkgCfgStruct{LogFlags: 0xffffffff, LogTraceFlags: 0xdeadbeef,
+		LogPrefix: "a \"quoted\" prefix: ", LogAlignFile: 5, LogAlignFunc: 6})
+	def, _ := fn.PkgCfgDef()
+	pkgCfgRoundTrip(t, def)
+
+	if got := fn.TraceFlagsText(fn.TrFlagsDef | 1<<30); got != "filenogps|fnbase|0x40000000" {
+		t.Errorf("TraceFlagsText got:%q", got)
+	}
+	if got, err := fn.ParseTraceFlags("fnbase, Trfilenogps 0x40000000"); got != fn.TrFlagsDef|1<<30 || err != nil {
+		t.Errorf("ParseTraceFlags got:%#x,%v", got, err)
+	}
+	if _, err := fn.ParseTraceFlags("fnbase|bogus"); err == nil || !strings.Contains(err.Error(), `"bogus"`) {
+		t.Errorf("ParseTraceFlags bogus err got:%v", err)
+	}
+}
+
+func TestPkgCfgMarshalForms(t *testing.T) {
+	def, _ := fn.PkgCfgDef()
+	data, _ := json.Marshal(def)
+	wantJSON := `{"log_flags":["date","shortfile","time"],"prefix":"LogFN: ",` +
+		`"trace_flags":["filenogps","fnbase"],"align_file":16,"align_func":0}`
+	if string(data) != wantJSON {
+		t.Errorf("json\n got:%s\nwant:%s", data, wantJSON)
+	}
+	text, _ := def.MarshalText()
+	wantText := "log_flags: date|shortfile|time\nprefix: \"LogFN: \"\n" +
+		"trace_flags: filenogps|fnbase\nalign_file: 16\nalign_func: 0\n"
+	if string(text) != wantText {
+		t.Errorf("text\n got:%q\nwant:%q", text, wantText)
+	}
+
+	// absent keys left unchanged
+	p := *def
+	if err := json.Unmarshal([]byte(`{"trace_flags":["begtime"]}`), &p); err != nil ||
+		p.LogTraceFlags != fn.Trbegtime || p.LogFlags != def.LogFlags || p.LogPrefix != def.LogPrefix {
+		t.Errorf("partial json got:%+v err:%v", p, err)
+	}
+	p = *def
+	if err := p.UnmarshalText([]byte("# comment\n\nprefix: pRe\n")); err != nil ||
+		p.LogPrefix != "pRe" || p.LogTraceFlags != def.LogTraceFlags {
+		t.Errorf("partial text got:%+v err:%v", p, err)
+	}
+
+	for _, tc := range []struct{ text, errSub string }{
+		{"align_file: x", "line 1"},
+		{"\nbogus: 1", `line 2: unknown key "bogus"`},
+		{"trace_flags: fnbase|nope", `unknown trace flag "nope"`},
+		{"prefix \"x\"", "missing ':'"},
+	} {
+		p = *def
+		err := p.UnmarshalText([]byte(tc.text))
+		if err == nil || !strings.Contains(err.Error(), tc.errSub) {
+			t.Errorf("UnmarshalText(%q) err got:%v want containing:%q", tc.text, err, tc.errSub)
+		}
+		if p != *def {
+			t.Errorf("UnmarshalText(%q) error modified config:%+v", tc.text, p)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"log_flags":["nope"]}`), &p); err == nil {
+		t.Error("json unknown log flag should error")
+	}
+}
+
+func TestLoadPkgCfg(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fnpkgcfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	def, _ := fn.PkgCfgDef()
+
+	tests := []struct {
+		name    string
+		content string
+		want    func(p *fn.PkgCfgStruct)
+		errSub  string
+	}{
+		{"json", `  {"trace_flags":["begtime","Trendtime"],"align_func":9}`,
+			func(p *fn.PkgCfgStruct) { p.LogTraceFlags = fn.Trbegtime | fn.Trendtime; p.LogAlignFunc = 9 }, ""},
+		{"text", "log_flags: 0\nprefix:\n",
+			func(p *fn.PkgCfgStruct) { p.LogFlags = 0; p.LogPrefix = "" }, ""},
+		{"badjson", `{"align_file":"x"}`, nil, "badjson"},
+		{"badtext", "nokey", nil, "badtext: fn: pkgcfg line 1"},
+	}
+	for _, tc := range tests {
+		path := filepath.Join(dir, tc.name)
+		if err := ioutil.WriteFile(path, []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := fn.LoadPkgCfg(path)
+		if tc.errSub != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.errSub) {
+				t.Errorf("%s: err got:%v want containing:%q", tc.name, err, tc.errSub)
+			}
+			continue
+		}
+		want := *def
+		tc.want(&want)
+		if err != nil || *got != want {
+			t.Errorf("%s:\n got:%+v err:%v\nwant:%+v", tc.name, got, err, want)
+		}
+	}
+	if _, err := fn.LoadPkgCfg(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("missing file err got:%v", err)
+	}
+}
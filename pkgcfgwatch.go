@@ -0,0 +1,215 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Labels of the log lines of WatchPkgCfg.
+const (
+	LcfgChangeLab = "CfgChange:"
+	LcfgRejectLab = "CfgReject:"
+)
+
+// PkgCfgWatchIntervalDef - default polling interval of WatchPkgCfg.
+const PkgCfgWatchIntervalDef = 2 * time.Second
+
+// cfgWatcher - state of a WatchPkgCfg polling loop.
+type cfgWatcher struct {
+	path    string
+	last    []byte   // content last seen
+	lastErr string   // last read error logged
+	output  string   // output key value last applied
+	file    *os.File // log output file opened by watcher if any
+}
+
+// WatchPkgCfg - applies the package config file at path (see LoadPkgCfg)
+// and then polls it every interval (PkgCfgWatchIntervalDef if < 1) for
+// changes. Each changed content is validated (see SetPkgCfgChecked) and
+// applied atomically logging a LcfgChangeLab line listing the changes,
+// or rejected logging a LcfgRejectLab line leaving the running config
+// undisturbed. Each content is taken on top of the package config defaults,
+// so removing a key from the file reverts it to its default. The config file
+// key output, which only WatchPkgCfg knows, names the log output: stdout,
+// stderr, discard or a file path opened for appending, if absent the log
+// output is unchanged. The initial config is not applied
+// and its error returned if invalid. The returned stop func ends polling,
+// a log output file opened by the watcher remains the log output.
+//
+//	Example: stop, err := fn.WatchPkgCfg("/etc/myapp/fn.cfg", 0)
+func WatchPkgCfg(path string, interval time.Duration) (stop func(), err error) {
+	if interval < 1 {
+		interval = PkgCfgWatchIntervalDef
+	}
+	w := &cfgWatcher{path: path}
+	if err := w.poll(); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+			}
+			if err := w.poll(); err != nil {
+				muLogt.Lock()
+				if logTraceFlags&Trlogignore == 0 {
+					logt.Printf("%s %s %v", LcfgRejectLab, path, err)
+				}
+				muLogt.Unlock()
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}, nil
+}
+
+// poll - applies the config file if its content changed since the last
+// poll, returns an error if it is invalid or unreadable (once per error).
+func (w *cfgWatcher) poll() error {
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		if err.Error() == w.lastErr {
+			return nil
+		}
+		w.lastErr = err.Error()
+		return err
+	}
+	w.lastErr = ""
+	if w.last != nil && bytes.Equal(data, w.last) {
+		return nil
+	}
+	w.last = data
+
+	p, output, err := parseWatchCfg(data)
+	if err != nil {
+		return err
+	}
+	p.LogAlignFile = clampAlign(p.LogAlignFile, LogAlignFileMax)
+	p.LogAlignFunc = clampAlign(p.LogAlignFunc, LogAlignFuncMax)
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	var wr io.Writer
+	var file *os.File
+	if output == "" {
+		output = w.output
+	}
+	if output != w.output {
+		if wr, file, err = openLogOutput(output); err != nil {
+			return err
+		}
+	}
+
+	muLogt.Lock()
+	old := curPkgCfg()
+	setPkgCfg(p, wr)
+	if diff := pkgCfgDiff(&old, p, w.output, output); diff != "" && logTraceFlags&Trlogignore == 0 {
+		logt.Printf("%s %s %s", LcfgChangeLab, w.path, diff)
+	}
+	muLogt.Unlock()
+
+	if wr != nil {
+		if w.file != nil {
+			w.file.Close()
+		}
+		w.file = file
+		w.output = output
+	}
+	return nil
+}
+
+// openLogOutput - returns the log output named by name: stdout, stderr,
+// discard or else a file path opened for appending, which is also returned.
+func openLogOutput(name string) (io.Writer, *os.File, error) {
+	switch name {
+	case "stdout":
+		return os.Stdout, nil, nil
+	case "stderr":
+		return os.Stderr, nil, nil
+	case "discard":
+		return ioutil.Discard, nil, nil
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f, nil
+}
+
+// parseWatchCfg - returns the package config of data as parsePkgCfg does
+// and the value of its output key, "" if absent.
+func parseWatchCfg(data []byte) (*PkgCfgStruct, string, error) {
+	var output string
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var j struct {
+			Output string `json:"output"`
+		}
+		if err := json.Unmarshal(data, &j); err != nil {
+			return nil, "", err
+		}
+		output = j.Output
+	} else {
+		// blank the output line keeping line numbers of errors intact
+		lines := bytes.Split(data, []byte("\n"))
+		for i, line := range lines {
+			s := strings.TrimSpace(string(line))
+			if j := strings.Index(s, ":"); j >= 0 && strings.TrimSpace(s[:j]) == "output" {
+				var err error
+				if output, err = unquoteText(strings.TrimSpace(s[j+1:])); err != nil {
+					return nil, "", fmt.Errorf("fn: pkgcfg line %d: %v", i+1, err)
+				}
+				lines[i] = nil
+			}
+		}
+		data = bytes.Join(lines, []byte("\n"))
+	}
+	p, err := parsePkgCfg(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return p, output, nil
+}
+
+// pkgCfgDiff - returns the changes from old to cur and from output oldOut
+// to curOut in the form of "key: old -> new" joined by "; ", "" if none.
+func pkgCfgDiff(old, cur *PkgCfgStruct, oldOut, curOut string) string {
+	var res []string
+	add := func(key, o, c string) {
+		if o != c {
+			res = append(res, fmt.Sprintf("%s: %s -> %s", key, o, c))
+		}
+	}
+	add("log_flags", LogFlagsText(old.LogFlags), LogFlagsText(cur.LogFlags))
+	add("prefix", strconv.Quote(old.LogPrefix), strconv.Quote(cur.LogPrefix))
+	add("trace_flags", TraceFlagsText(old.LogTraceFlags), TraceFlagsText(cur.LogTraceFlags))
+	add("align_file", strconv.Itoa(old.LogAlignFile), strconv.Itoa(cur.LogAlignFile))
+	add("align_func", strconv.Itoa(old.LogAlignFunc), strconv.Itoa(cur.LogAlignFunc))
+	add("trace_filter", strconv.Quote(old.LogTraceFilter), strconv.Quote(cur.LogTraceFilter))
+	add("output", strconv.Quote(oldOut), strconv.Quote(curOut))
+	return strings.Join(res, "; ")
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phcurtis/fn"
+)
+
+// writeAtomic - writes file at path via rename so a poller never reads it partially.
+func writeAtomic(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitFor - polls cond up to 5 seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for end := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(end) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func watchTraced() { defer fn.LogTrace()() }
+
+func TestWatchPkgCfg(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	dir, err := ioutil.TempDir("", "fnwatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var buf lockedBuf
+	fn.LogSetOutput(&buf)
+	path := filepath.Join(dir, "fn.cfg")
+
+	writeAtomic(t, path, "log_flags: 0\nprefix: \"\"\ntrace_flags: Trfnbase|Trnodur\n")
+	stop, err := fn.WatchPkgCfg(path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if got, _ := fn.PkgCfg(); got.LogFlags != 0 || got.LogTraceFlags != fn.Trfnbase|fn.Trnodur {
+		t.Fatalf("initial config not applied got:%+v", got)
+	}
+	want := fn.LcfgChangeLab + " " + path + " log_flags: date|shortfile|time -> 0; " +
+		`prefix: "LogFN: " -> ""; trace_flags: filenogps|fnbase -> fnbase|nodur` + "\n"
+	if s := buf.String(); s != want {
+		t.Errorf("initial change line\n got:%q\nwant:%q", s, want)
+	}
+
+	// filter change
+	writeAtomic(t, path, "log_flags: 0\nprefix: \"\"\ntrace_flags: fnbase|nodur\ntrace_filter: nomatch\n")
+	waitFor(t, "filter", func() bool { return fn.LogTraceFilter() == "nomatch" })
+	watchTraced()
+	if s := buf.String(); strings.Contains(s, "watchTraced") ||
+		!strings.Contains(s, `trace_filter: "" -> "nomatch"`) {
+		t.Errorf("filter unexpected output:\n%s", s)
+	}
+
+	// invalid updates rejected
+	before, _ := fn.PkgCfg()
+	for _, bad := range []string{
+		"trace_flags: nodur|allocs\n",
+		"trace_filter: \"(\"\n",
+		"bogus: 1\n",
+		"output: " + filepath.Join(dir, "nodir", "x.log") + "\n",
+	} {
+		n := strings.Count(buf.String(), fn.LcfgRejectLab)
+		writeAtomic(t, path, bad)
+		waitFor(t, "reject of "+bad, func() bool { return strings.Count(buf.String(), fn.LcfgRejectLab) > n })
+		if got, _ := fn.PkgCfg(); *got != *before {
+			t.Errorf("rejected %q changed config got:%+v", bad, got)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	if n := strings.Count(buf.String(), fn.LcfgRejectLab); n != 4 {
+		t.Errorf("reject lines got:%d want:4 (one per bad content)\n%s", n, buf.String())
+	}
+
+	// output change to a file
+	logPath := filepath.Join(dir, "trace.log")
+	writeAtomic(t, path, "log_flags: 0\nprefix: \"\"\ntrace_flags: fnbase|nodur\noutput: "+logPath+"\n")
+	waitFor(t, "output", func() bool { return fn.LogGetOutput() != &buf })
+	watchTraced()
+	data, _ := ioutil.ReadFile(logPath)
+	wantFile := fn.LcfgChangeLab + " " + path + ` trace_filter: "nomatch" -> ""; output: "" -> "` + logPath + "\"\n" +
+		fn.LbegTraceLab + pkgName + ".watchTraced\n" +
+		fn.LendTraceLab + pkgName + ".watchTraced\n"
+	if string(data) != wantFile {
+		t.Errorf("log file\n got:%q\nwant:%q", data, wantFile)
+	}
+	writeAtomic(t, path, "output: discard\n") // closes trace.log
+	waitFor(t, "discard", func() bool { return fn.LogGetOutput() == ioutil.Discard })
+	jsonPath := filepath.Join(dir, "json.log")
+	writeAtomic(t, path, `{"trace_flags":["fnbase"],"output":"`+jsonPath+`"}`)
+	waitFor(t, "json output", func() bool { return fn.LogGetOutput() != ioutil.Discard })
+	stop()
+	if data, _ := ioutil.ReadFile(jsonPath); !strings.Contains(string(data), `output: "discard" -> "`+jsonPath+`"`) {
+		t.Errorf("json log file got:%q", data)
+	}
+
+	if _, err := fn.WatchPkgCfg(filepath.Join(dir, "missing"), 0); !os.IsNotExist(err) {
+		t.Errorf("missing file err got:%v", err)
+	}
+	writeAtomic(t, path, "trace_flags: fnobegref|fbegrefincfile\n")
+	if _, err := fn.WatchPkgCfg(path, 0); err == nil {
+		t.Error("invalid initial config should error")
+	}
+}
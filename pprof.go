@@ -0,0 +1,75 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"context"
+	"path/filepath"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+)
+
+// pprof label keys applied when Trpproflabels is active.
+const (
+	PprofLabelFn   = "fn"
+	PprofLabelSpan = "span"
+)
+
+var muLabels sync.Mutex
+var labelCtxs = map[int64]context.Context{} // goid: labels context set by package fn
+
+// labelsBeg - applies pprof labels for the begun span on the current
+// goroutine, on top of those of the begin ctx when given (such as set by
+// pprof.Do) or else of an enclosing traced func. The begin ctx then carries
+// the labels too. Without either the labels in effect are unknown so none
+// are applied, as the end could not restore them.
+func labelsBeg(sp *trSpan, trflags int) {
+	name := sp.begFn
+	if trflags&Trfnbase > 0 {
+		name = filepath.Base(name)
+	}
+	labels := []string{PprofLabelFn, name}
+	if sp.id != 0 {
+		labels = append(labels, PprofLabelSpan, strconv.FormatUint(sp.id, 10))
+	}
+	sp.goid = Goid()
+
+	muLabels.Lock()
+	defer muLabels.Unlock()
+	prev := labelCtxs[sp.goid]
+	sp.prevOwn = prev != nil
+	if prev == nil {
+		prev = sp.ctx // labels of the caller
+	}
+	if prev == nil {
+		return
+	}
+	sp.prevLabels = prev
+	parent := sp.ctx
+	if parent == nil {
+		parent = prev
+	}
+	ctx := pprof.WithLabels(parent, pprof.Labels(labels...))
+	pprof.SetGoroutineLabels(ctx)
+	labelCtxs[sp.goid] = ctx
+	if sp.ctx != nil {
+		sp.ctx = ctx
+	}
+	sp.labeled = true
+}
+
+// labelsEnd - restores the pprof labels in effect before labelsBeg.
+func labelsEnd(sp *trSpan) {
+	muLabels.Lock()
+	defer muLabels.Unlock()
+	if sp.prevOwn {
+		labelCtxs[sp.goid] = sp.prevLabels
+		pprof.SetGoroutineLabels(sp.prevLabels)
+		return
+	}
+	delete(labelCtxs, sp.goid)
+	pprof.SetGoroutineLabels(sp.prevLabels)
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"runtime/pprof"
+	"strings"
+	"testing"
+
+	"github.com/phcurtis/fn"
+)
+
+// goroutineLabels - returns the goroutine profile with labels.
+func goroutineLabels() string {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 1)
+	return buf.String()
+}
+
+func pprofInner() (inner string) {
+	defer fn.LogTrace()()
+	return goroutineLabels()
+}
+
+func pprofOuter() (outer, inner, after string) {
+	_, end := fn.LogTraceCtx(context.Background())
+	defer end()
+	outer = goroutineLabels()
+	inner = pprofInner()
+	after = goroutineLabels()
+	return outer, inner, after
+}
+
+func TestTracePprofLabels(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	fn.LogSetOutput(ioutil.Discard)
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Trpproflabels)
+
+	outerLab := `"fn":"fn_test.pprofOuter"`
+	innerLab := `"fn":"fn_test.pprofInner"`
+	outer, inner, after := pprofOuter()
+	if !strings.Contains(outer, outerLab) || !strings.Contains(outer, `"span":"`) {
+		t.Errorf("outer labels missing %s", outerLab)
+	}
+	if !strings.Contains(inner, innerLab) {
+		t.Errorf("inner labels missing %s", innerLab)
+	}
+	if !strings.Contains(after, outerLab) || strings.Contains(after, innerLab) {
+		t.Errorf("outer labels not restored after inner end")
+	}
+	if got := goroutineLabels(); strings.Contains(got, outerLab) {
+		t.Errorf("labels not removed after outer end")
+	}
+}
+
+func TestTracePprofLabelsCtx(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	fn.LogSetOutput(ioutil.Discard)
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Trpproflabels)
+
+	reqLab := `"req":"r1"`
+	fnLab := `"fn":"fn_test.TestTracePprofLabelsCtx.func1"`
+	pprof.Do(context.Background(), pprof.Labels("req", "r1"), func(ctx context.Context) {
+		ctx, end := fn.LogTraceCtx(ctx)
+		in := goroutineLabels()
+		end()
+		after := goroutineLabels()
+		if !strings.Contains(in, reqLab) || !strings.Contains(in, fnLab) {
+			t.Errorf("labels during trace missing %s or %s", reqLab, fnLab)
+		}
+		if v, _ := pprof.Label(ctx, fn.PprofLabelFn); v != "fn_test.TestTracePprofLabelsCtx.func1" {
+			t.Errorf("ctx label %s got:%q", fn.PprofLabelFn, v)
+		}
+		if !strings.Contains(after, reqLab) || strings.Contains(after, fnLab) {
+			t.Errorf("caller labels not restored after end")
+		}
+	})
+}
+
+func TestTracePprofLabelsNoCtx(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	fn.LogSetOutput(ioutil.Discard)
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Trpproflabels)
+
+	reqLab := `"req":"r1"`
+	innerLab := `"fn":"fn_test.pprofInner"`
+	pprof.Do(context.Background(), pprof.Labels("req", "r1"), func(context.Context) {
+		// without a ctx the caller labels are unknown so left as is
+		if in := pprofInner(); !strings.Contains(in, reqLab) || strings.Contains(in, innerLab) {
+			t.Errorf("labels during trace without ctx changed")
+		}
+		if after := goroutineLabels(); !strings.Contains(after, reqLab) {
+			t.Errorf("caller labels lost after end")
+		}
+	})
+}
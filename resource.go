@@ -0,0 +1,111 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+)
+
+// ResDelta - resource usage deltas between the begin portion of a log trace
+// and its pairing end func, as enabled by Trallocs and Trgoroutines.
+// Allocation counts come from runtime/metrics, they are process wide so
+// concurrent goroutines contribute, and approximate since small allocations
+// are accounted for as per-P caches are flushed.
+type ResDelta struct {
+	Flags      int   // which of Trallocs, Trgoroutines were measured
+	Allocs     int64 // heap objects allocated
+	Bytes      int64 // heap bytes allocated
+	Goroutines int   // change in number of goroutines
+}
+
+// resSnap - resource usage sample taken at begin portion of a log trace.
+type resSnap struct {
+	flags      int
+	allocs     uint64
+	bytes      uint64
+	goroutines int
+}
+
+const (
+	metricAllocs = "/gc/heap/allocs:objects"
+	metricBytes  = "/gc/heap/allocs:bytes"
+)
+
+// readRes - samples the resources enabled in trflags.
+func readRes(trflags int) resSnap {
+	rs := resSnap{flags: trflags & (Trallocs | Trgoroutines)}
+	if rs.flags&Trallocs > 0 {
+		s := [2]metrics.Sample{{Name: metricAllocs}, {Name: metricBytes}}
+		metrics.Read(s[:])
+		if s[0].Value.Kind() == metrics.KindUint64 {
+			rs.allocs = s[0].Value.Uint64()
+		}
+		if s[1].Value.Kind() == metrics.KindUint64 {
+			rs.bytes = s[1].Value.Uint64()
+		}
+	}
+	if rs.flags&Trgoroutines > 0 {
+		rs.goroutines = runtime.NumGoroutine()
+	}
+	return rs
+}
+
+// delta - returns the resources used since rs, nil if none were sampled.
+func (rs resSnap) delta() *ResDelta {
+	if rs.flags == 0 {
+		return nil
+	}
+	now := readRes(rs.flags)
+	return &ResDelta{
+		Flags:      rs.flags,
+		Allocs:     int64(now.allocs - rs.allocs),
+		Bytes:      int64(now.bytes - rs.bytes),
+		Goroutines: now.goroutines - rs.goroutines,
+	}
+}
+
+// text - returns the deltas enabled in trflags as " Allocs:+N Bytes:+N Gor:+N".
+func (rd *ResDelta) text(trflags int) string {
+	if rd == nil {
+		return ""
+	}
+	var str string
+	if rd.Flags&trflags&Trallocs > 0 {
+		str += " Allocs:" + signed(rd.Allocs) + " Bytes:" + bytesStr(rd.Bytes)
+	}
+	if rd.Flags&trflags&Trgoroutines > 0 {
+		str += " Gor:" + signed(int64(rd.Goroutines))
+	}
+	return str
+}
+
+func signed(n int64) string {
+	if n >= 0 {
+		return "+" + strconv.FormatInt(n, 10)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// bytesStr - returns signed n in B, KiB, MiB or GiB such as +48KiB.
+func bytesStr(n int64) string {
+	sign := "+"
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	if n < 1024 {
+		return sign + strconv.FormatInt(n, 10) + "B"
+	}
+	v, unit := float64(n)/1024, "KiB"
+	if v >= 1024 {
+		v, unit = v/1024, "MiB"
+	}
+	if v >= 1024 {
+		v, unit = v/1024, "GiB"
+	}
+	return sign + strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64) + unit
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/phcurtis/fn"
+)
+
+var resSink [][]byte
+
+func resUser(stop chan struct{}) {
+	defer fn.LogTrace()()
+	for i := 0; i < 100; i++ {
+		resSink = append(resSink, make([]byte, 1024))
+	}
+	for i := 0; i < 2; i++ {
+		go func() { <-stop }()
+	}
+}
+
+func TestTraceResDelta(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	tests := []struct {
+		name    string
+		trflags int
+		want    string
+	}{
+		{"allocs", fn.Trallocs, ` Dur:\S+ Allocs:\+\d+ Bytes:\+\d+(\.\d)?KiB$`},
+		{"goroutines", fn.Trgoroutines | fn.Trnodur, `resUser Gor:\+2$`},
+		{"both", fn.Trallocs | fn.Trgoroutines, ` Allocs:\+\d+ Bytes:\+\S+ Gor:\+2$`},
+		{"none", fn.TrFlagsDef, `resUser Dur:\S+$`},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			buf := bytes.NewBufferString("")
+			fn.SetPkgCfgDef(false)
+			fn.LogSetOutput(buf)
+			fn.LogSetFlags(fn.LflagsOff)
+			fn.LogSetTraceFlags(v.trflags)
+			stop := make(chan struct{})
+			resUser(stop)
+			close(stop)
+			readStdoutCapLine(buf)
+			got := readStdoutCapLine(buf)
+			if !regexp.MustCompile(v.want).MatchString(got) {
+				t.Errorf("%s \n got:%s \nwant:%s", v.name, got, v.want)
+			}
+		})
+	}
+}
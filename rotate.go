@@ -0,0 +1,223 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// RotateBackupsDef - default number of rotated backup files kept.
+const RotateBackupsDef = 3
+
+// RotateOpts - options controlling when a RotateWriter rotates its file.
+type RotateOpts struct {
+	MaxSize    int64         // rotate before a write would exceed MaxSize bytes (0 no limit)
+	Interval   time.Duration // rotate once the file has been open for Interval (0 never)
+	MaxBackups int           // backups kept, RotateBackupsDef if 0, none if negative
+	Compress   bool          // gzip backups
+}
+
+// RotateWriter - an io.Writer appending to a file which is rotated on size
+// and/or interval, keeping up to MaxBackups older files named path.1
+// (most recent) through path.N, with a .gz suffix when compressed.
+// It is safe for concurrent use and may be passed to LogSetOutput or SetPkgCfg.
+type RotateWriter struct {
+	mu     sync.Mutex
+	path   string
+	opts   RotateOpts
+	f      *os.File
+	size   int64
+	opened time.Time
+	gz     sync.WaitGroup // pending compression of backup 1
+	gzErr  error          // its result, read after gz.Wait
+}
+
+// NewRotateWriter - returns a RotateWriter appending to path, creating it if needed.
+func NewRotateWriter(path string, opts RotateOpts) (*RotateWriter, error) {
+	if opts.MaxBackups == 0 {
+		opts.MaxBackups = RotateBackupsDef
+	}
+	rw := &RotateWriter{path: path, opts: opts}
+	if err := rw.open(); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+// open the current file for appending; caller holds rw.mu
+func (rw *RotateWriter) open() error {
+	f, err := os.OpenFile(rw.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rw.f = f
+	rw.size = fi.Size()
+	rw.opened = time.Now()
+	return nil
+}
+
+// Write - writes p to the file, rotating first if due. When the rotation
+// fails but the file could be reopened p is still written and the rotation
+// error returned.
+func (rw *RotateWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.f == nil {
+		return 0, os.ErrClosed
+	}
+	var rerr error
+	if rw.due(int64(len(p))) {
+		if rerr = rw.rotate(); rw.f == nil {
+			return 0, rerr
+		}
+	}
+	n, err := rw.f.Write(p)
+	rw.size += int64(n)
+	if err == nil {
+		err = rerr
+	}
+	return n, err
+}
+
+// due - returns true if writing n more bytes calls for a rotation.
+func (rw *RotateWriter) due(n int64) bool {
+	if rw.opts.MaxSize > 0 && rw.size > 0 && rw.size+n > rw.opts.MaxSize {
+		return true
+	}
+	return rw.opts.Interval > 0 && time.Since(rw.opened) >= rw.opts.Interval
+}
+
+// Rotate - forces a rotation of the file. The file is reopened even when
+// the rotation fails, so later writes are not lost.
+func (rw *RotateWriter) Rotate() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.f == nil {
+		return os.ErrClosed
+	}
+	return rw.rotate()
+}
+
+// rotate - moves the file to backup 1 and reopens path, always reopening
+// even if an earlier step failed; the first error is returned. Compression
+// of backup 1 runs in the background so writers are not held up by it;
+// caller holds rw.mu
+func (rw *RotateWriter) rotate() error {
+	err := rw.f.Close()
+	rw.f = nil
+	if gerr := rw.waitGzip(); err == nil {
+		err = gerr
+	}
+	moved, merr := rw.shift()
+	if err == nil {
+		err = merr
+	}
+	if oerr := rw.open(); oerr != nil {
+		return oerr
+	}
+	if moved && rw.opts.Compress {
+		name := rw.backupName(1)
+		rw.gz.Add(1)
+		go func() {
+			defer rw.gz.Done()
+			rw.gzErr = gzipFile(name)
+		}()
+	}
+	return err
+}
+
+// waitGzip - waits for a pending compression and returns its error.
+func (rw *RotateWriter) waitGzip() error {
+	rw.gz.Wait()
+	err := rw.gzErr
+	rw.gzErr = nil
+	return err
+}
+
+// shift - shifts the backups up one and moves the file to backup 1,
+// returning true if the file was moved; caller holds rw.mu
+func (rw *RotateWriter) shift() (bool, error) {
+	n := rw.opts.MaxBackups
+	if n < 1 {
+		return false, os.Remove(rw.path)
+	}
+
+	// shift path.1 .. path.N-1 up one, the oldest path.N falls off the end
+	exts := []string{"", ".gz"}
+	for _, ext := range exts {
+		os.Remove(rw.backupName(n) + ext)
+	}
+	for i := n - 1; i > 0; i-- {
+		for _, ext := range exts {
+			src := rw.backupName(i) + ext
+			if _, err := os.Stat(src); err != nil {
+				continue
+			}
+			if err := os.Rename(src, rw.backupName(i+1)+ext); err != nil {
+				return false, err
+			}
+		}
+	}
+	if err := os.Rename(rw.path, rw.backupName(1)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (rw *RotateWriter) backupName(i int) string {
+	return fmt.Sprintf("%s.%d", rw.path, i)
+}
+
+// gzipFile - compresses name to name.gz and removes name.
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
+
+// Close - closes the file after any pending compression completes;
+// subsequent writes return os.ErrClosed.
+func (rw *RotateWriter) Close() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.f == nil {
+		return os.ErrClosed
+	}
+	err := rw.f.Close()
+	rw.f = nil
+	if gerr := rw.waitGzip(); err == nil {
+		err = gerr
+	}
+	return err
+}
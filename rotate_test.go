@@ -0,0 +1,189 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phcurtis/fn"
+)
+
+func dirNames(t *testing.T, dir string) []string {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotateWriterSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     fn.RotateOpts
+		want     []string
+		wantLast string // content of tr.log.1
+	}{
+		{"plain", fn.RotateOpts{MaxSize: 10, MaxBackups: 2},
+			[]string{"tr.log", "tr.log.1", "tr.log.2"}, "line3\n"},
+		{"nobackups", fn.RotateOpts{MaxSize: 10, MaxBackups: -1},
+			[]string{"tr.log"}, ""},
+		{"compress", fn.RotateOpts{MaxSize: 10, Compress: true},
+			[]string{"tr.log", "tr.log.1.gz", "tr.log.2.gz", "tr.log.3.gz"}, "line3\n"},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "fnrotate")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "tr.log")
+			rw, err := fn.NewRotateWriter(path, v.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 5; i++ {
+				fmt.Fprintf(rw, "line%d\n", i) // 6 bytes each so 1 line per file
+			}
+			if err := rw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := dirNames(t, dir); strings.Join(got, ",") != strings.Join(v.want, ",") {
+				t.Errorf("\n got:%v \nwant:%v", got, v.want)
+			}
+			if b, _ := ioutil.ReadFile(path); string(b) != "line4\n" {
+				t.Errorf("current file got:%q want:%q", b, "line4\n")
+			}
+			if v.wantLast == "" {
+				return
+			}
+			var got []byte
+			if v.opts.Compress {
+				f, err := os.Open(path + ".1.gz")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				zr, err := gzip.NewReader(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, _ = ioutil.ReadAll(zr)
+			} else {
+				got, _ = ioutil.ReadFile(path + ".1")
+			}
+			if string(got) != v.wantLast {
+				t.Errorf("backup 1 got:%q want:%q", got, v.wantLast)
+			}
+		})
+	}
+}
+
+func TestRotateWriterInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fnrotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tr.log")
+	rw, err := fn.NewRotateWriter(path, fn.RotateOpts{Interval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rw.Close()
+	fmt.Fprint(rw, "a\n")
+	time.Sleep(30 * time.Millisecond)
+	fmt.Fprint(rw, "b\n")
+	if got, want := dirNames(t, dir), []string{"tr.log", "tr.log.1"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("\n got:%v \nwant:%v", got, want)
+	}
+}
+
+func TestRotateWriterConcurrent(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	dir, err := ioutil.TempDir("", "fnrotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tr.log")
+	rw, err := fn.NewRotateWriter(path, fn.RotateOpts{MaxSize: 4096, MaxBackups: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn.LogSetOutput(rw)
+
+	const goroutines, traces = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < traces; j++ {
+				fn.LogTrace()()
+			}
+		}()
+	}
+	wg.Wait()
+	fn.LogSetOutput(fn.LogGetOutputDef())
+	rw.Close()
+
+	var lines int
+	for _, name := range dirNames(t, dir) {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > 4096 {
+			t.Errorf("%s size:%d exceeds MaxSize", name, len(b))
+		}
+		lines += strings.Count(string(b), "\n")
+	}
+	if want := goroutines * traces * 2; lines != want {
+		t.Errorf("lines got:%d want:%d", lines, want)
+	}
+}
+
+func TestRotateWriterRotateFails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fnrotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tr.log")
+	// a non-empty dir in place of backup 1 makes the rename fail
+	if err := os.MkdirAll(filepath.Join(path+".1", "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	rw, err := fn.NewRotateWriter(path, fn.RotateOpts{MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rw.Close()
+	fmt.Fprint(rw, "a\n")
+	if err := rw.Rotate(); err == nil {
+		t.Error("Rotate err got:nil want:non-nil")
+	}
+	if _, err := fmt.Fprint(rw, "b\n"); err != nil {
+		t.Errorf("Write after failed Rotate err:%v", err)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "a\nb\n" {
+		t.Errorf("current file got:%q want:%q", b, "a\nb\n")
+	}
+}
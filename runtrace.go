@@ -0,0 +1,38 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"context"
+	"path/filepath"
+	"runtime/trace"
+)
+
+// runtraceBeg - opens a runtime/trace region named by the begun func,
+// within a new task when the span was begun with a context.
+func runtraceBeg(sp *trSpan, trflags int) {
+	name := sp.begFn
+	if trflags&Trfnbase > 0 {
+		name = filepath.Base(name)
+	}
+	ctx := sp.ctx
+	if ctx != nil {
+		sp.ctx, sp.task = trace.NewTask(ctx, name)
+		ctx = sp.ctx
+	} else {
+		ctx = context.Background()
+	}
+	sp.region = trace.StartRegion(ctx, name)
+}
+
+// runtraceEnd - ends region and task opened by runtraceBeg if any.
+func runtraceEnd(sp *trSpan) {
+	if sp.region != nil {
+		sp.region.End()
+	}
+	if sp.task != nil {
+		sp.task.End()
+	}
+}
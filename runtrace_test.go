@@ -0,0 +1,78 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"runtime/trace"
+	"testing"
+
+	"github.com/phcurtis/fn"
+	"github.com/phcurtis/fn/fntest"
+)
+
+func rtChild(ctx context.Context) uint64 {
+	ctx, end := fn.LogTraceCtx(ctx)
+	defer end()
+	return fn.CtxSpanID(ctx)
+}
+
+func rtParent(ctx context.Context) (parent, child uint64) {
+	ctx, end := fn.LogTraceCtx(ctx)
+	defer end()
+	defer fn.LogTrace()()
+	return fn.CtxSpanID(ctx), rtChild(ctx)
+}
+
+func TestLogTraceCtx(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	fn.LogSetOutput(ioutil.Discard)
+	rec := fntest.CaptureAll(t)
+
+	if got := fn.CtxSpanID(context.Background()); got != 0 {
+		t.Errorf("CtxSpanID without span got:%d want:0", got)
+	}
+	parent, child := rtParent(context.Background())
+	if parent == 0 || child == 0 || parent == child {
+		t.Fatalf("span ids parent:%d child:%d", parent, child)
+	}
+	for _, ev := range rec.Events() {
+		if ev.SpanID == child && ev.ParentID != parent {
+			t.Errorf("child %s ParentID got:%d want:%d", ev.Label, ev.ParentID, parent)
+		}
+		if ev.SpanID == parent && ev.ParentID != 0 {
+			t.Errorf("parent %s ParentID got:%d want:0", ev.Label, ev.ParentID)
+		}
+	}
+
+	fn.LogSetTraceFlags(fn.Trlogignore)
+	ctx := context.Background()
+	if got, end := fn.LogTraceCtx(ctx); got != ctx {
+		t.Errorf("Trlogignore should return ctx as is")
+	} else {
+		end()
+	}
+}
+
+func TestTraceRuntrace(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	fn.LogSetOutput(ioutil.Discard)
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Trruntrace)
+
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		t.Skipf("runtime/trace unavailable: %v", err)
+	}
+	rtParent(context.Background())
+	trace.Stop()
+
+	for _, name := range []string{"fn_test.rtParent", "fn_test.rtChild"} {
+		if !bytes.Contains(buf.Bytes(), []byte(name)) {
+			t.Errorf("execution trace missing region/task %s", name)
+		}
+	}
+}
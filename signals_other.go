@@ -0,0 +1,12 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package fn
+
+// HandleSignals - does nothing on systems without SIGUSR1 and SIGUSR2.
+func HandleSignals() (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package fn
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// LsignalLab - label of the log lines of HandleSignals.
+const LsignalLab = "Signal:"
+
+// sigVerbosity - Trace Flags levels cycled by SIGUSR2 see HandleSignals.
+var sigVerbosity = []int{0, Trbegtime | Trendtime, Trmicroboth}
+
+var sigActive bool // protected by muLogt
+
+// HandleSignals - opt-in handling of signals until the returned stop func
+// is called, it does nothing if signals are already being handled:
+//
+//	SIGUSR1 toggles Trlogignore (tracing off/on).
+//	SIGUSR2 cycles verbosity adding none, Trbegtime|Trendtime and
+//	        Trbegtime|Trendtime|Trmicroseconds to the Trace Flags.
+//	SIGQUIT logs the func stats (see Trfuncstats) and open traces
+//	        (see Tropentraces) even when Trlogignore is set, instead of
+//	        the runtime default of dumping goroutines and exiting, then
+//	        dumps the flight recorder if set (see LogSetFlightRecorder).
+//
+// Each is logged with a LsignalLab line.
+func HandleSignals() (stop func()) {
+	muLogt.Lock()
+	if sigActive {
+		muLogt.Unlock()
+		return func() {}
+	}
+	sigActive = true
+	muLogt.Unlock()
+
+	c := make(chan os.Signal, 4)
+	signal.Notify(c, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGQUIT)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-c:
+				handleSignal(sig)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+			wg.Wait()
+			muLogt.Lock()
+			sigActive = false
+			muLogt.Unlock()
+		})
+	}
+}
+
+func handleSignal(sig os.Signal) {
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	switch sig {
+	case syscall.SIGUSR1:
+		if logTraceFlags&Trlogignore > 0 {
+			logTraceFlags &^= Trlogignore
+			logt.Printf("%s SIGUSR1 tracing on", LsignalLab)
+		} else {
+			logt.Printf("%s SIGUSR1 tracing off", LsignalLab)
+			logTraceFlags |= Trlogignore
+		}
+	case syscall.SIGUSR2:
+		next := 1
+		for i, v := range sigVerbosity {
+			if logTraceFlags&Trmicroboth == v {
+				next = (i + 1) % len(sigVerbosity)
+				break
+			}
+		}
+		logTraceFlags = logTraceFlags&^Trmicroboth | sigVerbosity[next]
+		if logTraceFlags&Trlogignore == 0 {
+			logt.Printf("%s SIGUSR2 verbosity %d trace_flags: %s",
+				LsignalLab, next, TraceFlagsText(logTraceFlags))
+		}
+	case syscall.SIGQUIT:
+		stats := funcStatsAll()
+		ots := openTraces(0)
+		logt.Printf("%s SIGQUIT funcstats:%d opentraces:%d", LsignalLab, len(stats), len(ots))
+		if len(stats) > 0 {
+			FprintFuncStats(logt.Writer(), stats)
+		}
+		for _, ot := range ots {
+			logOpenTrace(ot)
+		}
+		if flightRec != nil {
+			logt.Printf("%s SIGQUIT flightrecorder:", LsignalLab)
+			// bypass the tee so the dump is not fed back into the recorder
+			flightRec.Dump(logOutputCur)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package fn_test
+
+import (
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/phcurtis/fn"
+)
+
+func sigTraced() { defer fn.LogTrace()() }
+
+func sigRecorded() { defer fn.LogTrace()() }
+
+func TestHandleSignals(t *testing.T) {
+	defer fn.SetPkgCfgDef(true)
+	fn.SetPkgCfgDef(true)
+	var buf lockedBuf
+	fn.LogSetOutput(&buf)
+	fn.LogSetFlags(0)
+	fn.LogSetPrefix("")
+	fn.FuncStatsReset()
+	defer fn.FuncStatsReset()
+
+	stop := fn.HandleSignals()
+	defer stop()
+	fn.HandleSignals()() // already handling, no-op stop
+
+	kill := func(sig syscall.Signal, what string, cond func() bool) {
+		t.Helper()
+		if err := syscall.Kill(syscall.Getpid(), sig); err != nil {
+			t.Fatal(err)
+		}
+		waitFor(t, what, cond)
+	}
+	flags := fn.LogTraceFlags
+
+	kill(syscall.SIGUSR1, "tracing off", func() bool { return flags()&fn.Trlogignore > 0 })
+	sigTraced()
+	kill(syscall.SIGUSR1, "tracing on", func() bool { return flags()&fn.Trlogignore == 0 })
+	if s := buf.String(); strings.Contains(s, "sigTraced") ||
+		!strings.Contains(s, fn.LsignalLab+" SIGUSR1 tracing off\n"+fn.LsignalLab+" SIGUSR1 tracing on\n") {
+		t.Errorf("SIGUSR1 unexpected output:\n%s", s)
+	}
+
+	for _, want := range []int{fn.Trbegtime | fn.Trendtime, fn.Trmicroboth, 0, fn.Trbegtime | fn.Trendtime} {
+		want := want
+		kill(syscall.SIGUSR2, "verbosity", func() bool { return flags()&fn.Trmicroboth == want })
+		if f := flags(); f&^fn.Trmicroboth != fn.TrFlagsDef {
+			t.Errorf("SIGUSR2 changed other flags got:%#x", f)
+		}
+	}
+	if !strings.Contains(buf.String(), fn.LsignalLab+" SIGUSR2 verbosity 2 trace_flags: begtime|endtime|filenogps|fnbase|microseconds\n") {
+		t.Errorf("SIGUSR2 unexpected output:\n%s", buf.String())
+	}
+
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Trfuncstats | fn.Tropentraces)
+	sigTraced()
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go openBlocker(started, release, &wg)
+	<-started
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Trlogignore) // dump regardless
+	kill(syscall.SIGQUIT, "dump", func() bool { return strings.Contains(buf.String(), fn.LopenTraceLab) })
+	s := buf.String()
+	if !strings.Contains(s, fn.LsignalLab+" SIGQUIT funcstats:1 opentraces:1\n") ||
+		!strings.Contains(s, "calls") || !strings.Contains(s, pkgName+".sigTraced\n") ||
+		!strings.Contains(s, fn.LopenTraceLab+" "+pkgName+".openBlocker() age:") {
+		t.Errorf("SIGQUIT unexpected output:\n%s", s)
+	}
+	close(release)
+	wg.Wait()
+
+	fr := fn.NewFlightRecorder(10, 0)
+	fn.LogSetFlightRecorder(fr)
+	defer fn.LogSetFlightRecorder(nil)
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Trnodur)
+	sigRecorded()
+	fn.LogSetTraceFlags(fn.TrFlagsDef | fn.Trlogignore)
+	hdr := fn.LsignalLab + " SIGQUIT flightrecorder:\n"
+	want := hdr + fn.LbegTraceLab + pkgName + ".sigRecorded\n" +
+		fn.LendTraceLab + pkgName + ".sigRecorded\n" + fn.LsignalLab + " SIGQUIT funcstats:"
+	kill(syscall.SIGQUIT, "recorder dump", func() bool { return strings.Count(buf.String(), hdr) == 2 }) // hdr is recorded too
+	if s := buf.String(); !strings.Contains(s, want) {
+		t.Errorf("SIGQUIT flight recorder unexpected output:\n%s", s)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Trace event kinds of a TraceEvent.
+const (
+	TraceBeg = iota // begin portion of LogTraceZZZ
+	TraceEnd        // pairing end func portion of LogTraceZZZ
+	TraceMsg        // unpaired message such as LogCondMsg
+)
+
+// TraceEvent - a trace begin, end or message event as handed to sinks.
+type TraceEvent struct {
+	Kind     int           // TraceBeg, TraceEnd or TraceMsg
+	Label    string        // trace label such as LbegTraceLab
+	Func     string        // full func name
+	File     string        // full filename
+	Line     int           // line num
+	BegFile  string        // TraceEnd: full filename of the begin portion
+	BegLine  int           // TraceEnd: line num of the begin portion
+	Msg      string        // begMsg, endMsg or msg
+	Attrs    []Attr        // key/value attributes
+	Time     time.Time     // time of event
+	Dur      time.Duration // TraceEnd: duration since the begin portion
+	Res      *ResDelta     // TraceEnd: resource deltas when Trallocs/Trgoroutines
+	SpanID   uint64        // id pairing begin and end events, 0 for TraceMsg
+	ParentID uint64        // id of the enclosing span see LogTraceCtx, 0 if none
+	Goid     int64         // goroutine id see Goid, only set if a sink has WantGoid
+}
+
+// Sink output formats.
+const (
+	SinkText = iota // same text form as the package log output
+	SinkJSON        // one JSON object per line
+)
+
+// Sink - an additional trace output with its own format, log flags,
+// trace flags, prefix, alignments and filter, independent of the package
+// log output configuration. Trlogignore in TraceFlags silences the sink,
+// while the package level Trlogignore still shuts off all tracing.
+type Sink struct {
+	Writer     io.Writer
+	Format     int    // SinkText or SinkJSON
+	LogFlags   int    // log.logger log flags (SinkText)
+	TraceFlags int    // Tr flags
+	Prefix     string // log.logger log prefix
+	AlignFile  int    // 'file' field minimum width (SinkText)
+	AlignFunc  int    // 'func' field minimum width (SinkText)
+
+	// Filter - if not nil only events it returns true for are written.
+	Filter func(ev *TraceEvent) bool
+
+	// WantGoid - set TraceEvent.Goid, as when filtering by goroutine.
+	// It costs a runtime.Stack call per event so it is off by default.
+	WantGoid bool
+
+	logt *log.Logger
+}
+
+var sinks []*Sink // protected by muLogt
+
+// LogAddSink - adds a copy of s to the trace outputs and returns a func
+// that removes it.
+func LogAddSink(s Sink) (remove func()) {
+	sk := &s
+	if sk.Format == SinkText {
+		sk.logt = log.New(sk.Writer, sk.Prefix, sk.LogFlags&^(log.Lshortfile|log.Llongfile))
+	}
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	sinks = append(sinks, sk)
+	return func() {
+		muLogt.Lock()
+		defer muLogt.Unlock()
+		for i, v := range sinks {
+			if v == sk {
+				sinks = append(sinks[:i], sinks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// LogSinksLen - returns the number of sinks added.
+func LogSinksLen() int {
+	muLogt.Lock()
+	defer muLogt.Unlock()
+	return len(sinks)
+}
+
+// sinksWrite - write event to each sink; caller holds muLogt.
+func sinksWrite(ev *TraceEvent) {
+	for _, s := range sinks {
+		if s.WantGoid {
+			ev.Goid = Goid()
+			break
+		}
+	}
+	for _, s := range sinks {
+		if s.TraceFlags&Trlogignore > 0 || (s.Filter != nil && !s.Filter(ev)) {
+			continue
+		}
+		if s.Format == SinkJSON {
+			s.Writer.Write(ev.jsonLine(s.Prefix, s.TraceFlags))
+			continue
+		}
+		var reffile, reflnum string
+		if ev.Kind == TraceEnd && ev.BegFile != "" {
+			reffile = filepath.Base(ev.BegFile)
+			reflnum = fmt.Sprintf(":%d", ev.BegLine)
+		}
+		s.logt.Print(ev.text(s.LogFlags, s.TraceFlags, s.AlignFile, s.AlignFunc, reffile, reflnum))
+	}
+}
+
+type jsonEvent struct {
+	Time    string                 `json:"time"`
+	Prefix  string                 `json:"prefix,omitempty"`
+	Label   string                 `json:"label"`
+	Func    string                 `json:"func"`
+	File    string                 `json:"file"`
+	Line    int                    `json:"line"`
+	BegLine int                    `json:"beg_line,omitempty"`
+	Msg     string                 `json:"msg,omitempty"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+	Dur     string                 `json:"dur,omitempty"`
+	DurNs   int64                  `json:"dur_ns,omitempty"`
+	Allocs  *int64                 `json:"allocs,omitempty"`
+	Bytes   *int64                 `json:"bytes,omitempty"`
+	Gor     *int                   `json:"goroutines,omitempty"`
+	SpanID  uint64                 `json:"span,omitempty"`
+	Parent  uint64                 `json:"parent,omitempty"`
+}
+
+// jsonLine - returns the event as a JSON object terminated by a newline.
+func (ev *TraceEvent) jsonLine(prefix string, trflags int) []byte {
+	je := jsonEvent{
+		Time:   ev.Time.Format(time.RFC3339Nano),
+		Prefix: strings.TrimSpace(prefix),
+		Label:  strings.TrimSuffix(ev.Label, ":"),
+		Func:   ev.Func,
+		File:   ev.File,
+		Line:   ev.Line,
+		Msg:    ev.Msg,
+		SpanID: ev.SpanID,
+		Parent: ev.ParentID,
+	}
+	if trflags&Trfnbase > 0 {
+		je.Func = filepath.Base(je.Func)
+	}
+	if trflags&Trfilenogps > 0 && strings.HasPrefix(je.File, gopathsrc) {
+		je.File = je.File[len(gopathsrc):]
+	}
+	if ev.Kind == TraceEnd {
+		if trflags&Trfnobegref == 0 {
+			je.BegLine = ev.BegLine
+		}
+		if trflags&Trnodur == 0 {
+			je.Dur = ev.Dur.String()
+			je.DurNs = int64(ev.Dur)
+		}
+		if rd := ev.Res; rd != nil {
+			if rd.Flags&trflags&Trallocs > 0 {
+				je.Allocs, je.Bytes = &rd.Allocs, &rd.Bytes
+			}
+			if rd.Flags&trflags&Trgoroutines > 0 {
+				je.Gor = &rd.Goroutines
+			}
+		}
+	}
+	if len(ev.Attrs) > 0 {
+		je.Attrs = make(map[string]interface{}, len(ev.Attrs))
+		for _, a := range ev.Attrs {
+			je.Attrs[a.Key] = jsonAttrVal(a.Value)
+		}
+	}
+	b, err := json.Marshal(je)
+	if err != nil {
+		b, _ = json.Marshal(map[string]string{"label": je.Label, "func": je.Func, "error": err.Error()})
+	}
+	return append(b, '\n')
+}
+
+// jsonAttrVal - returns v in a form json.Marshal renders sensibly.
+func jsonAttrVal(v interface{}) interface{} {
+	switch x := v.(type) {
+	case error:
+		return x.Error()
+	case fmt.Stringer:
+		return x.String()
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return v
+}
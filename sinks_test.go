@@ -0,0 +1,117 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/phcurtis/fn"
+)
+
+func TestLogAddSink(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	fn.LogSetOutput(ioutil.Discard)
+
+	var tbuf, jbuf, fbuf bytes.Buffer
+	rmt := fn.LogAddSink(fn.Sink{
+		Writer:     &tbuf,
+		Format:     fn.SinkText,
+		LogFlags:   log.Lshortfile,
+		TraceFlags: fn.Trfnbase | fn.Trnodur,
+		Prefix:     "T: ",
+	})
+	rmj := fn.LogAddSink(fn.Sink{
+		Writer:     &jbuf,
+		Format:     fn.SinkJSON,
+		TraceFlags: fn.TrFlagsDef,
+	})
+	rmf := fn.LogAddSink(fn.Sink{
+		Writer: &fbuf,
+		Filter: func(ev *fn.TraceEvent) bool { return ev.Kind == fn.TraceEnd },
+	})
+	if got := fn.LogSinksLen(); got != 3 {
+		t.Fatalf("LogSinksLen got:%d want:3", got)
+	}
+
+	fn.LogTraceAttrs("user", "gopher")("bytes", 3)
+
+	baseFN := pkgName + ".TestLogAddSink"
+	rexpfn1 := `sinks_test\.go`
+	wantt := []string{
+		"^T: " + rexpfn1 + `:\d+ ` + fn.LbegTraceAttrLab + baseFN + " user=gopher$",
+		"^T: " + rexpfn1 + `:\d+<:\d+> ` + fn.LendTraceAttrLab + baseFN + " bytes=3$",
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(tbuf.String(), "\n"), "\n") {
+		if i >= len(wantt) || !regexp.MustCompile(wantt[i]).MatchString(line) {
+			t.Errorf("text sink line %d unexpected:%q", i, line)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(jbuf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("json sink want 2 lines got:%q", jbuf.String())
+	}
+	var beg, end map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &beg); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &end); err != nil {
+		t.Fatal(err)
+	}
+	if beg["func"] != baseFN || beg["label"] != "BegTrAtr" ||
+		beg["attrs"].(map[string]interface{})["user"] != "gopher" {
+		t.Errorf("json begin unexpected:%s", lines[0])
+	}
+	if end["span"] != beg["span"] || end["dur"] == nil ||
+		end["attrs"].(map[string]interface{})["bytes"] != 3.0 {
+		t.Errorf("json end unexpected:%s", lines[1])
+	}
+
+	if got := strings.Count(fbuf.String(), "\n"); got != 1 ||
+		!strings.Contains(fbuf.String(), fn.LendTraceAttrLab) {
+		t.Errorf("filtered sink unexpected:%q", fbuf.String())
+	}
+
+	rmt()
+	rmj()
+	rmf()
+	rmf() // removing twice is harmless
+	if got := fn.LogSinksLen(); got != 0 {
+		t.Errorf("LogSinksLen got:%d want:0", got)
+	}
+}
+
+func TestSinkWantGoid(t *testing.T) {
+	defer fn.SetPkgCfgDef(true) // restore defaults at end of this func
+	fn.LogSetOutput(ioutil.Discard)
+
+	tests := []struct {
+		name     string
+		wantGoid bool
+		want     int64
+	}{
+		{"off", false, 0},
+		{"on", true, fn.Goid()},
+	}
+	for _, v := range tests {
+		var got int64 = -1
+		rm := fn.LogAddSink(fn.Sink{
+			Writer:   ioutil.Discard,
+			WantGoid: v.wantGoid,
+			Filter:   func(ev *fn.TraceEvent) bool { got = ev.Goid; return false },
+		})
+		fn.LogCondMsg(true, "m")
+		rm()
+		if got != v.want {
+			t.Errorf("%s: Goid got:%d want:%d", v.name, got, v.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// stackHash - FNV-1a 64 bit hash of frames func names (and line nums
+// when opts.Lines), independent of Base, Sep and RootFirst options.
+func stackHash(frames []runtime.Frame, opts *CStkOpts) uint64 {
+	h := fnv.New64a()
+	var buf []byte
+	for _, fr := range frames {
+		buf = append(buf[:0], fr.Function...)
+		if opts.Lines {
+			buf = append(buf, ':')
+			buf = strconv.AppendInt(buf, int64(fr.Line), 10)
+		}
+		buf = append(buf, 0)
+		h.Write(buf)
+	}
+	return h.Sum64()
+}
+
+// StackHash - returns a 64 bit fingerprint of the call stack relative to
+// where it was invoked from, of its func names (and line nums if opts.Lines)
+// after the opts frame filters and MaxDepth are applied.
+func StackHash(opts CStkOpts) uint64 {
+	return stackHash(cstkFrames(1, &opts), &opts)
+}
+
+// StackCount - a distinct call stack and the number of times it was added.
+type StackCount struct {
+	Hash  uint64
+	Count int
+	Stack string // see CStkWith
+}
+
+// StackRegistry - counts occurrences of distinct call stacks by fingerprint,
+// such as for finding the call paths that reach a hot func.
+type StackRegistry struct {
+	mu   sync.Mutex
+	opts CStkOpts
+	m    map[uint64]*StackCount
+}
+
+// NewStackRegistry - returns a StackRegistry fingerprinting and rendering
+// call stacks according to opts.
+func NewStackRegistry(opts CStkOpts) *StackRegistry {
+	return &StackRegistry{opts: opts, m: map[uint64]*StackCount{}}
+}
+
+// Add - counts the call stack relative to where it was invoked from
+// and returns its fingerprint.
+func (r *StackRegistry) Add() uint64 {
+	return r.add(2)
+}
+
+// LvlAdd - same as Add but for a given level relative to where it was
+// invoked from; lvl=Lme same as Add, lvl=Lpar starts at the parent func.
+func (r *StackRegistry) LvlAdd(lvl int) uint64 {
+	return r.add(lvl + 2)
+}
+
+func (r *StackRegistry) add(skip int) uint64 {
+	frames := cstkFrames(skip, &r.opts)
+	h := stackHash(frames, &r.opts)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sc := r.m[h]
+	if sc == nil {
+		sc = &StackCount{Hash: h, Stack: cstkString(frames, &r.opts)}
+		r.m[h] = sc
+	}
+	sc.Count++
+	return h
+}
+
+// Counts - returns the distinct call stacks, most frequent first.
+func (r *StackRegistry) Counts() []StackCount {
+	r.mu.Lock()
+	res := make([]StackCount, 0, len(r.m))
+	for _, sc := range r.m {
+		res = append(res, *sc)
+	}
+	r.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Stack < res[j].Stack
+	})
+	return res
+}
+
+// Fprint - writes the distinct call stacks with their counts, most frequent
+// first, one per line as: count hash stack.
+func (r *StackRegistry) Fprint(w io.Writer) error {
+	for _, sc := range r.Counts() {
+		if _, err := fmt.Fprintf(w, "%8d %016x %s\n", sc.Count, sc.Hash, sc.Stack); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset - discards all counts.
+func (r *StackRegistry) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.m = map[uint64]*StackCount{}
+}
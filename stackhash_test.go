@@ -0,0 +1,71 @@
+// Copyright 2017 phcurtis fn Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fn_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/phcurtis/fn"
+)
+
+var hotReg = fn.NewStackRegistry(fn.CStkOpts{Base: true, RootFirst: true, Sep: ">", NoStdlib: true})
+
+func hot() uint64   { return hotReg.LvlAdd(fn.Lpar) }
+func pathA() uint64 { return hot() }
+func pathB() uint64 { return hot() }
+
+func hashA(opts fn.CStkOpts) uint64 { return fn.StackHash(opts) }
+func hashB(opts fn.CStkOpts) uint64 { return fn.StackHash(opts) }
+
+func TestStackHash(t *testing.T) {
+	opts := fn.CStkOpts{}
+	if hashA(opts) != hashA(opts) {
+		t.Error("same call stack should have same hash")
+	}
+	if hashA(opts) == hashB(opts) {
+		t.Error("different call stacks should have different hashes")
+	}
+	lopts := fn.CStkOpts{Lines: true}
+	h1 := hashA(lopts)
+	h2 := hashA(lopts) // different line in this func
+	if h1 == h2 {
+		t.Error("with Lines different call lines should have different hashes")
+	}
+	if hashA(fn.CStkOpts{Base: true, Sep: "/", RootFirst: true}) != hashA(opts) {
+		t.Error("rendering options should not change the hash")
+	}
+}
+
+func TestStackRegistry(t *testing.T) {
+	hotReg.Reset()
+	var ha, hb uint64
+	for i := 0; i < 3; i++ {
+		ha = pathA()
+	}
+	hb = pathB()
+	if ha == hb {
+		t.Fatal("pathA and pathB should have different fingerprints")
+	}
+	counts := hotReg.Counts()
+	if len(counts) != 2 {
+		t.Fatalf("distinct stacks got:%d want:2", len(counts))
+	}
+	fns := pkgName + ".TestStackRegistry"
+	if c := counts[0]; c.Count != 3 || c.Hash != ha || c.Stack != fns+">"+pkgName+".pathA" {
+		t.Errorf("counts[0] unexpected:%+v", c)
+	}
+	if c := counts[1]; c.Count != 1 || c.Hash != hb || c.Stack != fns+">"+pkgName+".pathB" {
+		t.Errorf("counts[1] unexpected:%+v", c)
+	}
+	var buf bytes.Buffer
+	hotReg.Fprint(&buf)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(strings.TrimSpace(lines[0]), "3 ") ||
+		!strings.HasSuffix(lines[0], pkgName+".pathA") {
+		t.Errorf("unexpected Fprint output:\n%s", buf.String())
+	}
+}
@@ -48,9 +48,10 @@ func Test_forcepanichelplt(t *testing.T) {
 	defer SetPkgCfgDef(true) // restore defaults at end of this func
 
 	f := func() func() {
-		begTime, begFn, reffile, reflnum := helpltbeg(0, LbegTraceLab, "")
+		sp := helpltbeg(nil, 0, LbegTraceLab, "")
 		return func() {
-			helpltend(0, LendTraceLab, begTime, begFn, "hack"+reffile, reflnum, "")
+			sp.reffile = "hack" + sp.reffile
+			helpltend(0, LendTraceLab, sp, "")
 		}
 	}
 
@@ -70,3 +71,23 @@ func Test_forcepanichelplt(t *testing.T) {
 
 	f()() // make panic happen
 }
+
+func Test_bytesStr(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "+0B"},
+		{512, "+512B"},
+		{-1023, "-1023B"},
+		{48 * 1024, "+48KiB"},
+		{1536, "+1.5KiB"},
+		{3 << 20, "+3MiB"},
+		{-5 << 30, "-5GiB"},
+	}
+	for _, v := range tests {
+		if got := bytesStr(v.n); got != v.want {
+			t.Errorf("bytesStr(%d) got:%s want:%s", v.n, got, v.want)
+		}
+	}
+}